@@ -18,18 +18,17 @@ func main() {
 	}
 
 	for opts.Scan() {
-		opt, err := opts.Option()
-		if err != nil {
-			fmt.Printf("%s: error parsing option: %s\n", opts.ProgramName(), err)
-			continue
-		}
-
+		opt := opts.Opt()
 		if opt.HasArg() {
 			fmt.Printf("%s: got option %q with arg %q\n", opts.ProgramName(), opt.Opt, opt)
 		} else {
 			fmt.Printf("%s: got option %q\n", opts.ProgramName(), opt.Opt)
 		}
 	}
+	if err := opts.Err(); err != nil {
+		fmt.Printf("%s: error parsing option: %s\n", opts.ProgramName(), err)
+		return
+	}
 
 	fmt.Printf("%s: remaining arguments: %v\n", opts.ProgramName(), opts.Args())
 }
@@ -0,0 +1,142 @@
+package getopt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Bind parses argv and stores options into the fields of the struct pointed to by v.
+// Fields are bound using the "getopt" struct tag containing the option character,
+// optionally followed by a long name and the "arg" modifier, for example
+// `getopt:"v"`, `getopt:"v,verbose"` or `getopt:"o,output,arg"`. The long name is
+// informational only, options are always parsed as short ones. Supported field types
+// are bool, int, string and time.Duration. Bool fields take no argument and are set to
+// true when the option is present, unless the "arg" modifier is given, in which case
+// the argument is parsed by strconv.ParseBool. Fields of other types always require
+// an argument.
+// Bind returns remaining command line arguments.
+func Bind(v any, argv []string) ([]string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("bind target must be a pointer to struct, got %T", v)
+	}
+	st := rv.Elem()
+
+	fields := make(map[byte]reflect.Value)
+	var optstring strings.Builder
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Type().Field(i)
+		tag, ok := f.Tag.Lookup("getopt")
+		if !ok {
+			continue
+		}
+		c, hasArg, err := parseBindTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if _, ok := fields[c]; ok {
+			return nil, fmt.Errorf("field %s: duplicate option: -%c", f.Name, c)
+		}
+		if !f.IsExported() {
+			return nil, fmt.Errorf("field %s: unexported field", f.Name)
+		}
+		optstring.WriteByte(c)
+		switch {
+		case f.Type == durationType:
+			optstring.WriteByte(':')
+		case f.Type.Kind() == reflect.Bool:
+			if hasArg {
+				optstring.WriteByte(':')
+			}
+		case f.Type.Kind() == reflect.Int, f.Type.Kind() == reflect.String:
+			optstring.WriteByte(':')
+		default:
+			return nil, fmt.Errorf("field %s: unsupported type: %s", f.Name, f.Type)
+		}
+		fields[c] = st.Field(i)
+	}
+
+	s, err := NewArgv(optstring.String(), argv)
+	if err != nil {
+		return nil, err
+	}
+	for s.Scan() {
+		opt := s.Opt()
+		if err := bindOption(fields[opt.Opt], opt); err != nil {
+			return nil, fmt.Errorf("option -%c: %w", opt.Opt, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return s.Args(), nil
+}
+
+// parseBindTag parses the getopt struct tag "c[,long][,arg]" and returns the option
+// character and whether the "arg" modifier is present.
+func parseBindTag(tag string) (c byte, hasArg bool, err error) {
+	parts := strings.Split(tag, ",")
+	if len(parts[0]) != 1 || !isOptionChar(parts[0][0]) {
+		return 0, false, fmt.Errorf("invalid getopt tag: %q", tag)
+	}
+	for i, p := range parts[1:] {
+		switch {
+		case p == "arg":
+			hasArg = true
+		case i == 0 && (p == "" || isLongName(p)):
+			// long name, informational only
+		default:
+			return 0, false, fmt.Errorf("invalid getopt tag: %q: unknown modifier %q", tag, p)
+		}
+	}
+	return parts[0][0], hasArg, nil
+}
+
+func isLongName(name string) bool {
+	if len(name) < 2 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !isOptionChar(c) && (c != '-' || i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func bindOption(fv reflect.Value, opt *Option) error {
+	if fv.Type() == durationType {
+		v, err := time.ParseDuration(opt.String())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(v))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Bool:
+		if !opt.HasArg() {
+			fv.SetBool(true)
+			return nil
+		}
+		v, err := strconv.ParseBool(*opt.Arg)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(v)
+	case reflect.Int:
+		v, err := opt.Int()
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(v))
+	case reflect.String:
+		fv.SetString(opt.String())
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package getopt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	var opts struct {
+		Verbose bool          `getopt:"v"`
+		Output  string        `getopt:"o"`
+		Count   int           `getopt:"n"`
+		Timeout time.Duration `getopt:"t"`
+		Other   string
+	}
+
+	remaining, err := Bind(&opts, []string{"getopt", "-v", "-ofile", "-n", "42", "-t1m30s", "arg1", "arg2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Verbose || opts.Output != "file" || opts.Count != 42 || opts.Timeout != 90*time.Second || opts.Other != "" {
+		t.Errorf("unexpected bound values: %+v", opts)
+	}
+	if expected := []string{"arg1", "arg2"}; !reflect.DeepEqual(expected, remaining) {
+		t.Errorf("expected remaining %q, got %q", expected, remaining)
+	}
+}
+
+func TestBindLongNames(t *testing.T) {
+	var opts struct {
+		Verbose bool          `getopt:"v,verbose"`
+		Output  string        `getopt:"o,output,arg"`
+		Color   bool          `getopt:"c,color,arg"`
+		Timeout time.Duration `getopt:"t,,arg"`
+		Count   int           `getopt:"n,arg"`
+	}
+
+	remaining, err := Bind(&opts, []string{"getopt", "-v", "-o", "file", "-cfalse", "-t1s", "-n3", "arg1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Verbose || opts.Output != "file" || opts.Color || opts.Timeout != time.Second || opts.Count != 3 {
+		t.Errorf("unexpected bound values: %+v", opts)
+	}
+	if expected := []string{"arg1"}; !reflect.DeepEqual(expected, remaining) {
+		t.Errorf("expected remaining %q, got %q", expected, remaining)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	examples := []struct {
+		v    any
+		argv []string
+	}{
+		{
+			struct{}{},
+			[]string{"getopt"},
+		},
+		{
+			&struct {
+				F float64 `getopt:"f"`
+			}{},
+			[]string{"getopt"},
+		},
+		{
+			&struct {
+				A bool `getopt:"a"`
+				B bool `getopt:"a"`
+			}{},
+			[]string{"getopt"},
+		},
+		{
+			&struct {
+				A bool `getopt:"verbose"`
+			}{},
+			[]string{"getopt"},
+		},
+		{
+			&struct {
+				A bool `getopt:",verbose"`
+			}{},
+			[]string{"getopt"},
+		},
+		{
+			&struct {
+				A bool `getopt:"v,verbose,opt"`
+			}{},
+			[]string{"getopt"},
+		},
+		{
+			&struct {
+				C bool `getopt:"c,arg"`
+			}{},
+			[]string{"getopt", "-c", "maybe"},
+		},
+		{
+			&struct {
+				N int `getopt:"n"`
+			}{},
+			[]string{"getopt", "-n", "x"},
+		},
+		{
+			&struct {
+				N int `getopt:"n"`
+			}{},
+			[]string{"getopt", "-z"},
+		},
+	}
+
+	for i, ex := range examples {
+		if _, err := Bind(ex.v, ex.argv); err == nil {
+			t.Errorf("example %d: expected error", i+1)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package getopt
+
+import "sort"
+
+// Canonicalize parses argv according to optstring and returns an equivalent argv in
+// canonical form, suitable for comparing or deduplicating command lines.
+// In canonical form every option is a separate argv element, runs of consecutive
+// no-argument options are sorted in byte order, required arguments follow their option
+// as separate elements and optional arguments are attached to their option, using '='
+// for options declared with "::".
+// Remaining arguments are preceded by "--" if the first of them looks like an option
+// or could be taken for the argument of a preceding optional-argument option.
+func Canonicalize(optstring string, argv []string) ([]string, error) {
+	s, err := NewArgv(optstring, argv)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []string{argv[0]}
+	var run []string
+	flush := func() {
+		sort.Strings(run)
+		res = append(res, run...)
+		run = run[:0]
+	}
+
+	// last emitted option takes an optional argument but has none
+	bare := false
+	for s.Scan() {
+		opt := s.Opt()
+		if opt.Opt == 0 {
+			// operand returned in place
+			flush()
+			res = append(res, opt.String())
+			bare = false
+			continue
+		}
+		name := "-" + string(opt.Opt)
+		kind, _ := s.argKind(opt.Opt)
+		bare = kind == ArgOptional && !opt.HasArg()
+		if kind == ArgNone {
+			run = append(run, name)
+			continue
+		}
+		flush()
+		res = s.AppendOption(res, opt)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	args := s.Args()
+	if len(args) > 0 && (bare || len(args[0]) > 1 && args[0][0] == '-') {
+		res = append(res, "--")
+	}
+	return append(res, args...), nil
+}
+
+// SplitBundle splits a bundle of short options like -abc into separate options
+// ["-a", "-b", "-c"]. The split is purely syntactic and doesn't account for option
+// arguments. Tokens that aren't short option bundles, like long options, "-" or
+// operands, are returned unchanged as a single element slice.
+func SplitBundle(token string) []string {
+	if len(token) < 2 || token[0] != '-' {
+		return []string{token}
+	}
+	for i := 1; i < len(token); i++ {
+		if !isOptionChar(token[i]) {
+			return []string{token}
+		}
+	}
+	res := make([]string, len(token)-1)
+	for i := 1; i < len(token); i++ {
+		res[i-1] = "-" + token[i:i+1]
+	}
+	return res
+}
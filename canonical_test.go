@@ -0,0 +1,152 @@
+package getopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	examples := []struct {
+		optstring string
+		argv      []string
+		expected  []string
+	}{
+		{
+			"a:b",
+			[]string{"getopt", "-ba42"},
+			[]string{"getopt", "-b", "-a", "42"},
+		},
+		{
+			"abc",
+			[]string{"getopt", "-cb", "-a", "arg1"},
+			[]string{"getopt", "-a", "-b", "-c", "arg1"},
+		},
+		{
+			"a:bc",
+			[]string{"getopt", "-c", "-a", "x", "-cb"},
+			[]string{"getopt", "-c", "-a", "x", "-b", "-c"},
+		},
+		{
+			"z::v",
+			[]string{"getopt", "-vzfoo", "-z"},
+			[]string{"getopt", "-v", "-z=foo", "-z"},
+		},
+		{
+			"z::",
+			[]string{"getopt", "-z=", "x"},
+			[]string{"getopt", "-z=", "x"},
+		},
+		{
+			"z::",
+			[]string{"getopt", "-z==5"},
+			[]string{"getopt", "-z==5"},
+		},
+		{
+			"v",
+			[]string{"getopt", "-v", "--", "-w", "arg1"},
+			[]string{"getopt", "-v", "--", "-w", "arg1"},
+		},
+		{
+			"v",
+			[]string{"getopt", "-v", "--", "arg1"},
+			[]string{"getopt", "-v", "arg1"},
+		},
+		{
+			"z::",
+			[]string{"getopt", "-z", "--", "x"},
+			[]string{"getopt", "-z", "--", "x"},
+		},
+		{
+			":a:b",
+			[]string{"getopt", "-a", "--", "x"},
+			[]string{"getopt", "-a", "--", "x"},
+		},
+		{
+			"z::v",
+			[]string{"getopt", "-z", "-v", "--", "x"},
+			[]string{"getopt", "-z", "-v", "x"},
+		},
+	}
+
+	for i, ex := range examples {
+		actual, err := Canonicalize(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatalf("example %d: unexpected error: %s", i+1, err)
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+
+	if _, err := Canonicalize("a", []string{"getopt", "-x"}); err != InvalidOptionError('x') {
+		t.Errorf("expected %v, got %v", InvalidOptionError('x'), err)
+	}
+}
+
+func TestCanonicalizeRoundTrip(t *testing.T) {
+	examples := []struct {
+		optstring string
+		argv      []string
+	}{
+		{"a:b", []string{"getopt", "-ba42", "arg1"}},
+		{"abc", []string{"getopt", "-ab", "-c", "--", "-x"}},
+		{"z::v", []string{"getopt", "-vzfoo", "-z"}},
+		{"z::v", []string{"getopt", "-z", "--", "x"}},
+		{"z::v", []string{"getopt", "-vz", "--", "-", "x"}},
+		{":a:b", []string{"getopt", "-a", "--", "x"}},
+		{":a:b", []string{"getopt", "-ab", "-a", "val", "x"}},
+		{":a:b", []string{"getopt", "-a=5", "x"}},
+		{"z::v", []string{"getopt", "-z=", "x"}},
+		{"z::v", []string{"getopt", "-z==5", "-z=", "-zfoo"}},
+	}
+
+	for i, ex := range examples {
+		canonical, err := Canonicalize(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatalf("example %d: unexpected error: %s", i+1, err)
+		}
+		expected, expectedErrors, expectedRemaining := parseArgv(t, ex.optstring, ex.argv)
+		actual, actualErrors, actualRemaining := parseArgv(t, ex.optstring, canonical)
+		if len(expectedErrors) > 0 || len(actualErrors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s\n%s", i+1, dumpErrors(expectedErrors), dumpErrors(actualErrors))
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("example %d: %q: expected options\n%s\ngot\n%s", i+1, canonical, dumpOptions(expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(expectedRemaining, actualRemaining) {
+			t.Errorf("example %d: %q: expected remaining\n%s\ngot\n%s", i+1, canonical, dumpRemaining(expectedRemaining), dumpRemaining(actualRemaining))
+		}
+	}
+}
+
+func parseArgv(t *testing.T, optstring string, argv []string) ([]*Option, []error, []string) {
+	t.Helper()
+	scanner, err := NewArgv(optstring, argv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return collectOptions(scanner)
+}
+
+func TestSplitBundle(t *testing.T) {
+	examples := []struct {
+		token    string
+		expected []string
+	}{
+		{"-abc", []string{"-a", "-b", "-c"}},
+		{"-a", []string{"-a"}},
+		{"-a42", []string{"-a", "-4", "-2"}},
+		{"--long", []string{"--long"}},
+		{"--", []string{"--"}},
+		{"-", []string{"-"}},
+		{"-v=1", []string{"-v=1"}},
+		{"arg1", []string{"arg1"}},
+		{"", []string{""}},
+	}
+
+	for i, ex := range examples {
+		if actual := SplitBundle(ex.token); !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+}
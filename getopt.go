@@ -3,13 +3,30 @@
 package getopt
 
 import (
+	"context"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/netip"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// ErrVersionRequested is returned when version was set by SetVersion and scanner
+// encounters --version, or undeclared -V option if enabled by SetVersionShort.
+var ErrVersionRequested = errors.New("version requested")
+
+var errEmptyArgv = errors.New("argv must contain at least the program name")
+
 // InvalidOptionError is returned when scanner encounters an option not listed in optstring.
 type InvalidOptionError byte
 
@@ -24,12 +41,107 @@ func (e MissingArgumentError) Error() string {
 	return fmt.Sprintf("option -%c requires an argument", byte(e))
 }
 
+// BundledOptionError is returned when bundling is disabled and an option is combined
+// with other options in the same argv element.
+type BundledOptionError byte
+
+func (e BundledOptionError) Error() string {
+	return fmt.Sprintf("option -%c cannot be combined with other options", byte(e))
+}
+
+// UnexpectedArgumentError is returned in strict mode when an argument is attached to
+// an option that takes no argument using '=', like in -v=1.
+type UnexpectedArgumentError byte
+
+func (e UnexpectedArgumentError) Error() string {
+	return fmt.Sprintf("option -%c doesn't take an argument", byte(e))
+}
+
+// DuplicateOptionError is returned when an option listed in SetNoRepeat is repeated.
+type DuplicateOptionError byte
+
+func (e DuplicateOptionError) Error() string {
+	return fmt.Sprintf("option -%c may be given only once", byte(e))
+}
+
+// InvalidUTF8Error is returned when UTF-8 validation is enabled by SetValidateUTF8 and
+// an option element of argv is not valid UTF-8.
+type InvalidUTF8Error struct {
+	// Index of the element in argv
+	Index int
+	// Invalid element
+	Arg string
+}
+
+func (e InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("argument %d is not valid UTF-8: %q", e.Index, e.Arg)
+}
+
+// TooManyOccurrencesError is returned when option is given more times than allowed
+// by SetMaxCount.
+type TooManyOccurrencesError struct {
+	// Option name
+	Opt byte
+	// Maximum allowed number of occurrences
+	Max int
+}
+
+func (e TooManyOccurrencesError) Error() string {
+	return fmt.Sprintf("option -%c may be given at most %d time(s)", e.Opt, e.Max)
+}
+
+// ValidationError is returned when option argument is rejected by the validator set
+// by SetValidator.
+type ValidationError struct {
+	// Option name
+	Opt byte
+	// Validator error
+	Err error
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("invalid argument for option -%c: %s", e.Opt, e.Err)
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Option argument kinds.
+const (
+	// Option takes no argument
+	ArgNone = iota
+	// Option requires an argument
+	ArgRequired
+	// Option may have an optional argument
+	ArgOptional
+)
+
+// StopReason describes why Scan stopped returning options.
+type StopReason int
+
+// Scan stop reasons.
+const (
+	// Scan hasn't stopped yet
+	StopNone StopReason = iota
+	// All arguments were scanned
+	StopEnd
+	// Scanning was terminated by "--" or the token set by SetTerminator
+	StopDoubleDash
+	// Scanning stopped at the first operand
+	StopOperand
+	// Scanning stopped because of an error
+	StopError
+)
+
 // Option contains option name and optional argument value.
 type Option struct {
 	// Option name
 	Opt byte
 	// Option argument, if any
 	Arg *string
+	// Arguments collected by an option set by SetGreedy
+	Args []string
 }
 
 func (o *Option) HasArg() bool {
@@ -43,6 +155,119 @@ func (o *Option) String() string {
 	return ""
 }
 
+// StringOk returns option argument and true if the argument is present, distinguishing
+// a present empty argument from a missing one.
+func (o *Option) StringOk() (string, bool) {
+	if o.Arg != nil {
+		return *o.Arg, true
+	}
+	return "", false
+}
+
+// SecretString holds a sensitive option argument. It's masked when formatted,
+// use Reveal to access the value.
+type SecretString struct {
+	value string
+}
+
+// String returns masked value.
+func (s SecretString) String() string {
+	return "***"
+}
+
+// GoString returns masked value for %#v formatting.
+func (s SecretString) GoString() string {
+	return `getopt.SecretString("***")`
+}
+
+// Reveal returns the secret value.
+func (s SecretString) Reveal() string {
+	return s.value
+}
+
+// Secret returns option argument as SecretString protected from accidental logging.
+func (o *Option) Secret() SecretString {
+	return SecretString{o.String()}
+}
+
+// Equal returns true if o and other have the same name and argument.
+func (o *Option) Equal(other *Option) bool {
+	if o == nil || other == nil {
+		return o == other
+	}
+	if o.Opt != other.Opt || o.HasArg() != other.HasArg() || o.String() != other.String() {
+		return false
+	}
+	if len(o.Args) != len(other.Args) {
+		return false
+	}
+	for i := range o.Args {
+		if o.Args[i] != other.Args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonOption is the JSON representation of Option.
+type jsonOption struct {
+	Opt  string   `json:"opt"`
+	Arg  *string  `json:"arg,omitempty"`
+	Args []string `json:"args,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, option is encoded as {"opt":"v","arg":"value"}
+// with arg omitted if the option has no argument.
+func (o Option) MarshalJSON() ([]byte, error) {
+	v := jsonOption{Arg: o.Arg, Args: o.Args}
+	if o.Opt != 0 {
+		v.Opt = string(o.Opt)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (o *Option) UnmarshalJSON(data []byte) error {
+	var v jsonOption
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if len(v.Opt) > 1 {
+		return fmt.Errorf("invalid option name: %q", v.Opt)
+	}
+	*o = Option{Arg: v.Arg, Args: v.Args}
+	if v.Opt != "" {
+		o.Opt = v.Opt[0]
+	}
+	return nil
+}
+
+// StringDefault returns option argument or def if the option has no argument.
+func (o *Option) StringDefault(def string) string {
+	if o.HasArg() {
+		return o.String()
+	}
+	return def
+}
+
+// IntDefault returns option argument as int or def if the argument is missing or
+// is not a valid integer.
+func (o *Option) IntDefault(def int) int {
+	if v, err := o.Int(); err == nil && o.HasArg() {
+		return v
+	}
+	return def
+}
+
+// BoolDefault returns option argument parsed by strconv.ParseBool or def if the
+// argument is missing or is not a valid boolean.
+func (o *Option) BoolDefault(def bool) bool {
+	if v, err := strconv.ParseBool(o.String()); err == nil && o.HasArg() {
+		return v
+	}
+	return def
+}
+
 func (o *Option) Int() (int, error) {
 	v, err := strconv.ParseInt(o.String(), 10, 64)
 	if err != nil {
@@ -107,22 +332,410 @@ func (o *Option) Float64() (float64, error) {
 	return v, nil
 }
 
+// Percent parses option argument as a floating point number, a trailing '%' divides
+// it by 100, so both 50% and 0.5 yield 0.5.
+func (o *Option) Percent() (float64, error) {
+	if o.Arg == nil {
+		return 0, MissingArgumentError(o.Opt)
+	}
+	s := strings.TrimSuffix(*o.Arg, "%")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if len(s) < len(*o.Arg) {
+		v /= 100
+	}
+	return v, nil
+}
+
+// WithUnit parses option argument as a floating point number followed by an optional
+// unit suffix from units and returns the number multiplied by the unit factor, like
+// 250ms with units {"ms": 0.001, "s": 1} yields 0.25. A bare number uses factor 1.
+// The longest matching suffix is used.
+func (o *Option) WithUnit(units map[string]float64) (float64, error) {
+	if o.Arg == nil {
+		return 0, MissingArgumentError(o.Opt)
+	}
+	s, factor := *o.Arg, 1.0
+	unit := ""
+	for u, f := range units {
+		if len(u) > len(unit) && strings.HasSuffix(s, u) {
+			unit, factor = u, f
+		}
+	}
+	v, err := strconv.ParseFloat(s[:len(s)-len(unit)], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number or unknown unit: %q", s)
+	}
+	return v * factor, nil
+}
+
+// IntRangePair parses option argument as an integer range "lo-hi", like 10-20.
+// Both bounds may be negative, like in -5--1, and lo must not be greater than hi.
+func (o *Option) IntRangePair() (lo, hi int, err error) {
+	if o.Arg == nil {
+		return 0, 0, MissingArgumentError(o.Opt)
+	}
+	s := *o.Arg
+	// separator is the first '-' following a digit
+	sep := -1
+	for i := 1; i < len(s); i++ {
+		if s[i] == '-' && '0' <= s[i-1] && s[i-1] <= '9' {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 {
+		return 0, 0, fmt.Errorf("invalid range: %q", s)
+	}
+	if lo, err = strconv.Atoi(s[:sep]); err != nil {
+		return 0, 0, err
+	}
+	if hi, err = strconv.Atoi(s[sep+1:]); err != nil {
+		return 0, 0, err
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("invalid range: %q: lower bound is greater than upper bound", s)
+	}
+	return lo, hi, nil
+}
+
+// OneOf returns the element of allowed matching option argument. If fold is true,
+// matching is case-insensitive. If abbrev is true, an unambiguous prefix of an allowed
+// value matches it, an exact match always takes precedence over prefixes.
+func (o *Option) OneOf(allowed []string, fold, abbrev bool) (string, error) {
+	if o.Arg == nil {
+		return "", MissingArgumentError(o.Opt)
+	}
+	v := *o.Arg
+	equal := func(a, b string) bool {
+		if fold {
+			return strings.EqualFold(a, b)
+		}
+		return a == b
+	}
+	for _, a := range allowed {
+		if equal(a, v) {
+			return a, nil
+		}
+	}
+	if abbrev && v != "" {
+		var candidates []string
+		for _, a := range allowed {
+			if len(a) >= len(v) && equal(a[:len(v)], v) {
+				candidates = append(candidates, a)
+			}
+		}
+		switch len(candidates) {
+		case 1:
+			return candidates[0], nil
+		case 0:
+		default:
+			return "", fmt.Errorf("ambiguous value %q, candidates: %s", v, strings.Join(candidates, ", "))
+		}
+	}
+	return "", fmt.Errorf("invalid value %q, expected one of: %s", v, strings.Join(allowed, ", "))
+}
+
+// Lines splits option argument into newline separated lines, a trailing empty line is
+// dropped. It returns nil if the option has no argument.
+func (o *Option) Lines() []string {
+	if o.Arg == nil {
+		return nil
+	}
+	lines := strings.Split(*o.Arg, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// StringMap parses option argument as a comma separated list of key=value pairs.
+// A missing or empty argument yields an empty map.
+func (o *Option) StringMap() (map[string]string, error) {
+	res := make(map[string]string)
+	if o.Arg == nil || *o.Arg == "" {
+		return res, nil
+	}
+	for _, kv := range strings.Split(*o.Arg, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid key=value pair: %q", kv)
+		}
+		res[k] = v
+	}
+	return res, nil
+}
+
+// AppendTo appends option and its argument, if any, to dst as command line arguments
+// and returns the extended slice. Non-empty arguments are attached to the option,
+// arguments that are empty or start with '=' are appended as a separate element.
+// This works for both required and optional arguments, except for empty optional
+// arguments, which require knowing the option argument kind, see Scanner.AppendOption.
+// Arguments collected by an option set by SetGreedy follow the option, but the
+// terminator isn't known to the option and isn't appended.
+func (o *Option) AppendTo(dst []string) []string {
+	name := "-" + string(o.Opt)
+	switch {
+	case o.Args != nil:
+		return append(append(dst, name), o.Args...)
+	case o.Arg == nil:
+		return append(dst, name)
+	case *o.Arg == "" || (*o.Arg)[0] == '=':
+		return append(dst, name, *o.Arg)
+	default:
+		return append(dst, name+*o.Arg)
+	}
+}
+
+// Create creates or truncates the file named by option argument.
+// If the argument is "-", it returns standard output, which isn't closed by Close.
+// Files are returned as *os.File.
+func (o *Option) Create() (io.WriteCloser, error) {
+	return o.openOutput(os.O_WRONLY | os.O_CREATE | os.O_TRUNC)
+}
+
+// Append opens the file named by option argument for appending, creating it if needed.
+// If the argument is "-", it returns standard output, which isn't closed by Close.
+// Files are returned as *os.File.
+func (o *Option) Append() (io.WriteCloser, error) {
+	return o.openOutput(os.O_WRONLY | os.O_CREATE | os.O_APPEND)
+}
+
+func (o *Option) openOutput(flag int) (io.WriteCloser, error) {
+	if o.Arg == nil {
+		return nil, MissingArgumentError(o.Opt)
+	}
+	if *o.Arg == "-" {
+		return stdout{os.Stdout}, nil
+	}
+	f, err := os.OpenFile(*o.Arg, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// stdout wraps standard output so that closing it is a no-op.
+type stdout struct {
+	*os.File
+}
+
+func (stdout) Close() error {
+	return nil
+}
+
+// Glob returns names of files matching the pattern in option argument, as returned by
+// filepath.Glob. Relative patterns are matched against the current working directory.
+func (o *Option) Glob() ([]string, error) {
+	if o.Arg == nil {
+		return nil, MissingArgumentError(o.Opt)
+	}
+	return filepath.Glob(*o.Arg)
+}
+
+var namedColors = map[string][3]uint8{
+	"black":   {0x00, 0x00, 0x00},
+	"white":   {0xff, 0xff, 0xff},
+	"red":     {0xff, 0x00, 0x00},
+	"green":   {0x00, 0x80, 0x00},
+	"blue":    {0x00, 0x00, 0xff},
+	"yellow":  {0xff, 0xff, 0x00},
+	"cyan":    {0x00, 0xff, 0xff},
+	"magenta": {0xff, 0x00, 0xff},
+	"gray":    {0x80, 0x80, 0x80},
+}
+
+// Color parses option argument as #rrggbb, #rgb or a basic color name (black, white,
+// red, green, blue, yellow, cyan, magenta, gray) and returns its RGB components.
+func (o *Option) Color() (r, g, b uint8, err error) {
+	if o.Arg == nil {
+		return 0, 0, 0, MissingArgumentError(o.Opt)
+	}
+	s := *o.Arg
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c[0], c[1], c[2], nil
+	}
+	if len(s) == 4 && s[0] == '#' {
+		// expand #rgb to #rrggbb
+		s = string([]byte{'#', s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, fmt.Errorf("invalid color: %q", *o.Arg)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color: %q", *o.Arg)
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), nil
+}
+
+// UUID parses option argument as a UUID in the canonical 8-4-4-4-12 hex form,
+// optionally enclosed in braces. Hex digits are case-insensitive.
+func (o *Option) UUID() ([16]byte, error) {
+	var u [16]byte
+	if o.Arg == nil {
+		return u, MissingArgumentError(o.Opt)
+	}
+	s := *o.Arg
+	if len(s) == 38 && s[0] == '{' && s[37] == '}' {
+		s = s[1:37]
+	}
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID: %q", *o.Arg)
+	}
+	h := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(h)); err != nil {
+		return [16]byte{}, fmt.Errorf("invalid UUID: %q", *o.Arg)
+	}
+	return u, nil
+}
+
+// Unmarshal decodes option argument into u using its UnmarshalText method.
+func (o *Option) Unmarshal(u encoding.TextUnmarshaler) error {
+	if o.Arg == nil {
+		return MissingArgumentError(o.Opt)
+	}
+	return u.UnmarshalText([]byte(*o.Arg))
+}
+
+// Addr parses option argument as an IP address using netip.ParseAddr.
+func (o *Option) Addr() (netip.Addr, error) {
+	if o.Arg == nil {
+		return netip.Addr{}, MissingArgumentError(o.Opt)
+	}
+	return netip.ParseAddr(*o.Arg)
+}
+
+// AddrPort parses option argument as an IP address and port using netip.ParseAddrPort.
+func (o *Option) AddrPort() (netip.AddrPort, error) {
+	if o.Arg == nil {
+		return netip.AddrPort{}, MissingArgumentError(o.Opt)
+	}
+	return netip.ParseAddrPort(*o.Arg)
+}
+
+// MAC parses option argument as a hardware address using net.ParseMAC.
+func (o *Option) MAC() (net.HardwareAddr, error) {
+	if o.Arg == nil {
+		return nil, MissingArgumentError(o.Opt)
+	}
+	return net.ParseMAC(*o.Arg)
+}
+
+// DurationSlice parses option argument as a comma separated list of durations.
+func (o *Option) DurationSlice() ([]time.Duration, error) {
+	return SliceOf(o, time.ParseDuration)
+}
+
+// SliceOf splits option argument on commas and converts each element using parse.
+func SliceOf[T any](o *Option, parse func(string) (T, error)) ([]T, error) {
+	if o.Arg == nil {
+		return nil, MissingArgumentError(o.Opt)
+	}
+	elems := strings.Split(*o.Arg, ",")
+	res := make([]T, len(elems))
+	for i, e := range elems {
+		v, err := parse(e)
+		if err != nil {
+			return nil, fmt.Errorf("element %d (%q): %w", i, e, err)
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// Scanf parses option argument according to format using fmt.Sscanf.
+func (o *Option) Scanf(format string, a ...any) (int, error) {
+	if o.Arg == nil {
+		return 0, MissingArgumentError(o.Opt)
+	}
+	return fmt.Sscanf(*o.Arg, format, a...)
+}
+
+// OptionResult contains an option or an error sent by Scanner.Stream.
+type OptionResult struct {
+	Option *Option
+	Err    error
+}
+
 // Scanner contains option scanner data.
 type Scanner struct {
 	// Command line arguments
 	argv []string
+	// Deferred command line arguments source
+	provider func() []string
 	// Accepted option characters
-	optstring string
+	set *OptSet
 	// Current argv index
 	optind int
 	// Current argv element
 	arg string
 	// Current index in the arg
 	optpos int
+	// Last parsed option
+	opt *Option
 	// Last error, if any
 	err error
+	// Option parsing is terminated by the terminator or unknown option handler
+	terminated bool
+	// Reason Scan returned false
+	stop StopReason
+	// Argv index of the last returned option
+	optindex int
+	// All options parsed so far
+	options []*Option
+	// Argv indexes of all options parsed so far
+	indexes []int
+	// Number of argv elements prepended by NewArgs, subtracted from reported indexes
+	base int
 	// Basename of argv[0]
 	progname string
+	// Program name set by SetProgramName
+	prognameOverride string
+	// End of options marker
+	terminator string
+	// Keep empty option arguments
+	allowEmptyArg bool
+	// Accept options not listed in optstring
+	permissive bool
+	// Allow several options in one argv element
+	bundling bool
+	// Reject '=' attached to no-argument options
+	strictNoArg bool
+	// Unknown options handler
+	unknownFunc func(rune) (bool, error)
+	// Remaining arguments handler
+	operandFunc func(string)
+	// Remaining arguments transformation
+	argTransform func(string) string
+	// Reject option elements that are not valid UTF-8
+	validateUTF8 bool
+	// Translate "-" operands to ""
+	stdinDash bool
+	// Continue scanning past operands
+	interspersed bool
+	// Stop at the first operand if POSIXLY_CORRECT is set
+	honorPosixlyCorrect bool
+	// Operands set aside in interspersed mode
+	operands []string
+	// Number of operands encountered by Scan
+	noperands int
+	// Program version
+	version string
+	// Undeclared -V requests version
+	versionShort bool
+	// Options that may be given only once
+	noRepeat map[byte]bool
+	// Greedy options and their terminators
+	greedy map[byte]string
+	// Maximum number of occurrences per option
+	maxCount map[byte]int
+	// Option argument validators
+	validators map[byte]func(string) error
+	// Destinations for option arguments
+	collectors map[byte]*[]string
 }
 
 // New returns a new options scanner using os.Args as the command line arguments source.
@@ -134,68 +747,281 @@ func New(optstring string) (*Scanner, error) {
 	return NewArgv(optstring, os.Args)
 }
 
-// New returns a new options scanner using passed argv as the command line argument source.
+// NewArgv returns a new options scanner using passed argv as the command line argument source.
+// The first element of argv is treated as the program name, so argv must not be empty.
 // The option string optstring may contain the following elements:
 // individual characters, and characters followed by a colon to indicate an
 // option argument is to follow.
 // If optstring starts with ':' then all option argument are treated as optional.
+// If optstring starts with '+' then scanning stops at the first operand even in
+// interspersed mode. If optstring starts with '-' then operands are returned in place
+// as options with Opt == 0 and the operand as the argument. Prefix characters ':', '+'
+// and '-' may be combined in any order.
 func NewArgv(optstring string, argv []string) (*Scanner, error) {
-	for _, c := range []byte(optstring) {
-		if !isOptionChar(c) && c != ':' {
-			return nil, fmt.Errorf("invalid optstring character: %q", c)
-		}
+	if len(argv) == 0 {
+		return nil, errEmptyArgv
 	}
-	return &Scanner{
-		argv:      argv,
-		optstring: optstring,
-		optind:    1,
-		optpos:    1,
-		progname:  path.Base(argv[0]),
-	}, nil
+	set, err := Compile(optstring)
+	if err != nil {
+		return nil, err
+	}
+	return set.Scanner(argv), nil
+}
+
+// NewArgs returns a new options scanner using passed args, which don't include the
+// program name, as the command line argument source. The program name is taken from
+// os.Args. Indexes reported by OptionIndex, OptionIndexes and InvalidUTF8Error are
+// relative to args.
+func NewArgs(optstring string, args []string) (*Scanner, error) {
+	progname := ""
+	if len(os.Args) > 0 {
+		progname = os.Args[0]
+	}
+	s, err := NewArgv(optstring, append([]string{progname}, args...))
+	if err != nil {
+		return nil, err
+	}
+	s.base = 1
+	return s, nil
+}
+
+// NewString returns a new options scanner using the command line cmdline split into
+// words by WordSplit as the command line argument source.
+func NewString(optstring, cmdline string) (*Scanner, error) {
+	argv, err := WordSplit(cmdline)
+	if err != nil {
+		return nil, err
+	}
+	return NewArgv(optstring, argv)
+}
+
+// NewFunc returns a new options scanner using argv returned by provider as the command
+// line argument source. The provider is called once, when the scanner is first used.
+func NewFunc(optstring string, provider func() []string) (*Scanner, error) {
+	set, err := Compile(optstring)
+	if err != nil {
+		return nil, err
+	}
+	s := set.Scanner(nil)
+	s.err = nil
+	s.provider = provider
+	return s, nil
 }
 
-// Scan advances options scanner to the next option.
-// It returns false when there are no more options or parsing is terminated by "--".
+// load calls the argv provider set by NewFunc, if it wasn't called yet.
+func (s *Scanner) load() {
+	if s.provider == nil {
+		return
+	}
+	argv := s.provider()
+	s.provider = nil
+	s.SetArgs(argv)
+}
+
+// Scan advances options scanner to the next option, which will then be available
+// through the Opt or Option method.
+// It returns false when there are no more options, parsing is terminated by "--"
+// (or the token set by SetTerminator) or after the error reported by the previous call.
+// The terminator is consumed once, subsequent calls keep returning false.
+// A lone "-" is treated as an operand and also stops option parsing, so does an element
+// starting with a digit option not listed in optstring, like a negative number, unless
+// permissive mode is enabled by SetPermissive.
+//
+// Like bufio.Scanner, Scan returns false when it encounters an error, which is then
+// reported by Err:
+//
+//	for s.Scan() {
+//		use(s.Opt())
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+//
+// Loops checking the error returned by Option inside the loop body no longer see
+// errors and must check Err (or Option) after the loop instead.
 func (s *Scanner) Scan() bool {
-	if s.optind == len(s.argv) || s.err != nil {
+	s.load()
+	if s.err != nil || s.terminated {
 		return false
 	}
+	s.stop = StopNone
 
-	s.arg = s.argv[s.optind]
-	if s.arg == "--" {
+	for {
+		if s.optind >= len(s.argv) {
+			s.stop = StopEnd
+			return false
+		}
+		s.arg = s.argv[s.optind]
+		if s.terminator != "" && s.arg == s.terminator {
+			s.optind += 1
+			s.terminated = true
+			s.stop = StopDoubleDash
+			return false
+		}
+		if s.version != "" && s.arg == "--version" {
+			s.optindex = s.optind
+			s.optind += 1
+			s.opt, s.err = nil, ErrVersionRequested
+			return false
+		}
+		if !s.isOperand(s.arg) {
+			break
+		}
+		if s.set.mode&ModeInOrder != 0 {
+			// return operand in place
+			arg := s.arg
+			s.optindex = s.optind
+			s.optind += 1
+			s.noperands += 1
+			s.opt = &Option{Arg: &arg}
+			return true
+		}
+		if !s.permute() {
+			s.stop = StopOperand
+			return false
+		}
+		// set operand aside and continue scanning
+		s.operands = append(s.operands, s.arg)
 		s.optind += 1
-		return false
+		s.noperands += 1
 	}
-	if len(s.arg) < 2 || s.arg[0] != '-' || !isOptionChar(s.arg[1]) {
+
+	s.optindex = s.optind
+	if s.validateUTF8 && s.optpos == 1 && !utf8.ValidString(s.arg) {
+		s.opt, s.err = nil, InvalidUTF8Error{Index: s.optind - s.base, Arg: s.arg}
 		return false
 	}
 
+	if s.unknownFunc != nil && s.isUnknown(s.arg[s.optpos]) {
+		return s.unknown()
+	}
+
+	s.opt, s.err = s.next()
+	if s.err == nil {
+		s.err = s.check(s.opt)
+	}
+	if s.err != nil {
+		s.opt = nil
+		return false
+	}
+	s.options = append(s.options, s.opt)
+	s.indexes = append(s.indexes, s.optindex)
+	if dst, ok := s.collectors[s.opt.Opt]; ok && s.opt.HasArg() {
+		*dst = append(*dst, *s.opt.Arg)
+	}
 	return true
 }
 
-// Option returns the next option or an error when it encounters an unknown option or
-// an option that is missing a required argument.
+// Try runs fn against a copy of the scanner and advances s to the state of the copy
+// only if fn returns nil, so failed speculative parsing leaves s untouched. It returns
+// the error returned by fn. Arguments collected by CollectInto during fn are appended
+// to their destinations only if fn succeeds. fn is expected to scan, changing scanner
+// configuration in fn is not supported.
+func (s *Scanner) Try(fn func(*Scanner) error) error {
+	s.load()
+	c := *s
+	// force reallocation on append so that s keeps its contents
+	c.options = s.options[:len(s.options):len(s.options)]
+	c.indexes = s.indexes[:len(s.indexes):len(s.indexes)]
+	c.operands = s.operands[:len(s.operands):len(s.operands)]
+	// collect into temporary slices until fn succeeds
+	if s.collectors != nil {
+		c.collectors = make(map[byte]*[]string, len(s.collectors))
+		for opt := range s.collectors {
+			c.collectors[opt] = new([]string)
+		}
+	}
+	if err := fn(&c); err != nil {
+		return err
+	}
+	for opt, dst := range s.collectors {
+		*dst = append(*dst, *c.collectors[opt]...)
+	}
+	c.collectors = s.collectors
+	*s = c
+	return nil
+}
+
+// StopReason returns the reason the last call to Scan returned false, or StopNone
+// if it returned true. StopError is returned as soon as an error is encountered.
+func (s *Scanner) StopReason() StopReason {
+	if s.err != nil {
+		return StopError
+	}
+	return s.stop
+}
+
+// HasOption returns true if the current scanner position points at an option element.
+// Unlike Scan, it doesn't parse the option or consume the terminator.
+func (s *Scanner) HasOption() bool {
+	s.load()
+	if s.err != nil || s.terminated || s.optind >= len(s.argv) {
+		return false
+	}
+	if s.optpos > 1 {
+		// inside an options bundle
+		return true
+	}
+	arg := s.argv[s.optind]
+	if s.terminator != "" && arg == s.terminator {
+		return false
+	}
+	if s.version != "" && arg == "--version" {
+		return true
+	}
+	return !s.isOperand(arg)
+}
+
+// Option returns the option parsed by the last call to Scan or the error that made
+// Scan return false, like an unknown option or an option that is missing a required
+// argument.
 // If optstring starts with ':' then all arguments are treated as optional and missing
 // arguments do not cause errors.
 func (s *Scanner) Option() (*Option, error) {
+	return s.opt, s.err
+}
+
+// Opt returns the option parsed by the last call to Scan.
+// It returns nil if Scan returned false.
+func (s *Scanner) Opt() *Option {
+	return s.opt
+}
+
+// next parses the option at the current scanner position.
+func (s *Scanner) next() (*Option, error) {
 	optopt := s.arg[s.optpos]
 
-	idx := strings.IndexByte(s.optstring, optopt)
-	if idx < 0 {
-		s.err = InvalidOptionError(optopt)
-		return nil, s.err
+	kind, ok := s.argKind(optopt)
+	if !ok && optopt == 'V' && s.versionShort && s.version != "" {
+		return nil, ErrVersionRequested
+	}
+	if !ok {
+		if s.permissive && isOptionChar(optopt) {
+			return s.undeclared(optopt)
+		}
+		return nil, InvalidOptionError(optopt)
+	}
+
+	if term, ok := s.greedy[optopt]; ok {
+		return s.greedyArgs(optopt, term), nil
 	}
 
-	hasArg := idx < len(s.optstring)-1 && s.optstring[idx+1] == ':'
-	optionalArg := s.optstring[0] == ':' || idx < len(s.optstring)-2 && s.optstring[idx+2] == ':'
+	hasArg := kind != ArgNone
+	optionalArg := kind == ArgOptional
 
 	if hasArg {
 		// option with an argument
-		if len(s.arg) > s.optpos+1 {
+		if len(s.arg) > s.optpos+1 && s.arg[s.optpos+1] == '=' && s.set.kinds[optopt] == ArgOptional {
+			// explicit assignment to an optional argument, -z= gives present empty value
+			arg := s.arg[s.optpos+2:]
+			s.optind += 1
+			s.optpos = 1
+			return &Option{Opt: optopt, Arg: &arg}, nil
+		} else if len(s.arg) > s.optpos+1 {
 			// option and argument are in the same argv element
 			res := &Option{
 				Opt: optopt,
-				Arg: optArg(s.arg[s.optpos+1:]),
+				Arg: s.argValue(s.arg[s.optpos+1:]),
 			}
 			s.optind += 1
 			s.optpos = 1
@@ -206,7 +1032,7 @@ func (s *Scanner) Option() (*Option, error) {
 				// consume next argv element
 				res := &Option{
 					Opt: optopt,
-					Arg: optArg(s.argv[s.optind+1]),
+					Arg: s.argValue(s.argv[s.optind+1]),
 				}
 				s.optind += 2
 				s.optpos = 1
@@ -217,7 +1043,7 @@ func (s *Scanner) Option() (*Option, error) {
 				if optarg != "" && optarg[0] != '-' {
 					res := &Option{
 						Opt: optopt,
-						Arg: optArg(s.argv[s.optind+1]),
+						Arg: s.argValue(s.argv[s.optind+1]),
 					}
 					s.optind += 2
 					s.optpos = 1
@@ -239,12 +1065,17 @@ func (s *Scanner) Option() (*Option, error) {
 					Opt: optopt,
 				}, nil
 			} else {
-				s.err = MissingArgumentError(optopt)
-				return nil, s.err
+				return nil, MissingArgumentError(optopt)
 			}
 		}
 	} else {
 		// no-argument option
+		if s.strictNoArg && len(s.arg) > s.optpos+1 && s.arg[s.optpos+1] == '=' {
+			return nil, UnexpectedArgumentError(optopt)
+		}
+		if !s.bundling && len(s.arg) > s.optpos+1 {
+			return nil, BundledOptionError(optopt)
+		}
 		s.optpos += 1
 		if len(s.arg) == s.optpos {
 			// arg has the only one option
@@ -257,23 +1088,779 @@ func (s *Scanner) Option() (*Option, error) {
 	}
 }
 
+// isUnknown returns true if the option c would be rejected as invalid.
+func (s *Scanner) isUnknown(c byte) bool {
+	if _, ok := s.argKind(c); ok {
+		return false
+	}
+	if c == 'V' && s.versionShort && s.version != "" {
+		return false
+	}
+	return !s.permissive || !isOptionChar(c)
+}
+
+// unknown passes the unknown option at the current scanner position to the handler
+// set by SetUnknownHandler and acts on its decision.
+func (s *Scanner) unknown() bool {
+	optopt := s.arg[s.optpos]
+	asOperand, err := s.unknownFunc(rune(optopt))
+	if err != nil {
+		s.opt, s.err = nil, err
+		return false
+	}
+	if !asOperand {
+		// ignore the option
+		s.optpos += 1
+		if len(s.arg) == s.optpos {
+			s.optind += 1
+			s.optpos = 1
+		}
+		return s.Scan()
+	}
+
+	operand := "-" + s.arg[s.optpos:]
+	s.optind += 1
+	s.optpos = 1
+	s.noperands += 1
+	if s.set.mode&ModeInOrder != 0 {
+		s.opt = &Option{Arg: &operand}
+		return true
+	}
+	s.operands = append(s.operands, operand)
+	if s.permute() {
+		return s.Scan()
+	}
+	s.terminated = true
+	s.stop = StopOperand
+	return false
+}
+
+// check validates the parsed option against scanner constraints.
+func (s *Scanner) check(opt *Option) error {
+	if s.noRepeat[opt.Opt] && s.count(opt.Opt) > 0 {
+		return DuplicateOptionError(opt.Opt)
+	}
+	if n, ok := s.maxCount[opt.Opt]; ok && s.count(opt.Opt) >= n {
+		return TooManyOccurrencesError{Opt: opt.Opt, Max: n}
+	}
+	if fn, ok := s.validators[opt.Opt]; ok && opt.HasArg() {
+		if err := fn(*opt.Arg); err != nil {
+			return ValidationError{Opt: opt.Opt, Err: err}
+		}
+	}
+	return nil
+}
+
+// count returns the number of times the option c was parsed.
+func (s *Scanner) count(c byte) int {
+	n := 0
+	for _, o := range s.options {
+		if o.Opt == c {
+			n++
+		}
+	}
+	return n
+}
+
+// argKind looks up argument kind of the option c in optstring.
+func (s *Scanner) argKind(c byte) (int, bool) {
+	return s.set.argKind(c)
+}
+
+// greedyArgs collects arguments of the greedy option up to the terminator term.
+func (s *Scanner) greedyArgs(optopt byte, term string) *Option {
+	res := &Option{
+		Opt: optopt,
+	}
+	if len(s.arg) > s.optpos+1 {
+		// first argument is in the same argv element
+		res.Args = append(res.Args, s.arg[s.optpos+1:])
+	}
+	s.optind += 1
+	s.optpos = 1
+	for s.optind < len(s.argv) {
+		arg := s.argv[s.optind]
+		s.optind += 1
+		if arg == term {
+			break
+		}
+		res.Args = append(res.Args, arg)
+	}
+	return res
+}
+
+// undeclared parses an option not listed in optstring in permissive mode.
+func (s *Scanner) undeclared(optopt byte) (*Option, error) {
+	if len(s.arg) > s.optpos+1 && s.arg[s.optpos+1] == '=' {
+		// argument is attached using '='
+		res := &Option{
+			Opt: optopt,
+			Arg: s.argValue(s.arg[s.optpos+2:]),
+		}
+		s.optind += 1
+		s.optpos = 1
+		return res, nil
+	}
+	if !s.bundling && len(s.arg) > s.optpos+1 {
+		return nil, BundledOptionError(optopt)
+	}
+	s.optpos += 1
+	if len(s.arg) == s.optpos {
+		s.optind += 1
+		s.optpos = 1
+	}
+	return &Option{
+		Opt: optopt,
+	}, nil
+}
+
 // Args returns remaining command line arguments.
+// In interspersed mode operands found between options come first.
 func (s *Scanner) Args() []string {
-	if s.optind < len(s.argv) {
-		return s.argv[s.optind:]
+	s.load()
+	var args []string
+	if len(s.operands) > 0 {
+		args = append([]string(nil), s.operands...)
+		args = append(args, s.argv[s.optind:]...)
+	} else if s.optind < len(s.argv) {
+		args = s.argv[s.optind:]
+	}
+	if args == nil || !s.stdinDash && s.argTransform == nil {
+		return args
+	}
+	res := make([]string, len(args))
+	for i, arg := range args {
+		res[i] = s.operand(arg)
+	}
+	return res
+}
+
+// CommandArgs returns remaining command line arguments split into a command name and
+// its arguments, suitable for exec.Command. A terminator not yet consumed by Scan is
+// skipped. It returns an empty name if there are no remaining arguments.
+func (s *Scanner) CommandArgs() (name string, args []string) {
+	rest := s.Args()
+	if len(rest) > 0 && len(s.operands) == 0 && !s.terminated && s.terminator != "" && rest[0] == s.terminator {
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return "", nil
+	}
+	return rest[0], rest[1:]
+}
+
+// OperandCount returns the number of operands Scan encountered between options, which
+// are set aside in interspersed mode or returned in place with the '-' optstring prefix.
+// Unlike len(Args()), it doesn't include arguments following the point where scanning
+// stopped.
+func (s *Scanner) OperandCount() int {
+	return s.noperands
+}
+
+// CheckArgs returns an error if the number of remaining command line arguments
+// returned by Args is less than min or greater than max. A negative max means no
+// upper bound.
+func (s *Scanner) CheckArgs(min, max int) error {
+	n := len(s.Args())
+	switch {
+	case n < min:
+		return fmt.Errorf("too few arguments: expected at least %d, got %d", min, n)
+	case max >= 0 && n > max:
+		return fmt.Errorf("too many arguments: expected at most %d, got %d", max, n)
 	}
 	return nil
 }
 
-// ProgramName returns basename of argv[0].
+// NextArg consumes and returns the next remaining command line argument, the first
+// element of Args. It returns ok == false when there are no remaining arguments.
+func (s *Scanner) NextArg() (arg string, ok bool) {
+	s.load()
+	if len(s.operands) > 0 {
+		arg, s.operands = s.operands[0], s.operands[1:]
+		return s.operand(arg), true
+	}
+	if s.optind < len(s.argv) {
+		s.optind += 1
+		return s.operand(s.argv[s.optind-1]), true
+	}
+	return "", false
+}
+
+// SetOperandFunc sets function fn called by DrainArgs for each remaining argument.
+func (s *Scanner) SetOperandFunc(fn func(string)) {
+	s.operandFunc = fn
+}
+
+// DrainArgs consumes all remaining command line arguments, calling the function set
+// by SetOperandFunc for each of them in order.
+func (s *Scanner) DrainArgs() {
+	for {
+		arg, ok := s.NextArg()
+		if !ok {
+			return
+		}
+		if s.operandFunc != nil {
+			s.operandFunc(arg)
+		}
+	}
+}
+
+// operand translates remaining command line argument according to scanner settings.
+func (s *Scanner) operand(arg string) string {
+	if s.stdinDash && arg == "-" {
+		return ""
+	}
+	if s.argTransform != nil {
+		return s.argTransform(arg)
+	}
+	return arg
+}
+
+// Walk scans the command line in order, calling onOption for each option and onOperand
+// for each operand. Unlike Scan, it continues past operands; all arguments following
+// the terminator are passed to onOperand, so are unknown options converted to operands
+// by the handler set by SetUnknownHandler and operands set aside by previous calls to
+// Scan. Walk stops at the first error reported by the scanner or returned by a callback.
+func (s *Scanner) Walk(onOption func(*Option) error, onOperand func(string) error) error {
+	// operands are reported in place rather than set aside
+	defer func(interspersed bool) {
+		s.interspersed = interspersed
+	}(s.interspersed)
+	s.interspersed = false
+
+	for {
+		if s.err != nil {
+			// error reported by a previous call to Scan
+			return s.err
+		}
+		// report operands set aside by previous calls to Scan or converted by the
+		// unknown option handler
+		for len(s.operands) > 0 {
+			arg := s.operands[0]
+			s.operands = s.operands[1:]
+			if err := onOperand(arg); err != nil {
+				return err
+			}
+		}
+
+		optind := s.optind
+		if s.Scan() {
+			var err error
+			if opt := s.Opt(); opt.Opt == 0 {
+				err = onOperand(opt.String())
+			} else {
+				err = onOption(opt)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if s.err != nil {
+			return s.err
+		}
+		if len(s.operands) > 0 && s.stop == StopOperand {
+			// unknown option converted to operand, continue scanning
+			s.terminated = false
+			continue
+		}
+		if s.optind > optind {
+			// option parsing is terminated
+			for s.optind < len(s.argv) {
+				s.optind += 1
+				if err := onOperand(s.argv[s.optind-1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if s.optind >= len(s.argv) {
+			return nil
+		}
+		s.optind += 1
+		if err := onOperand(s.argv[s.optind-1]); err != nil {
+			return err
+		}
+	}
+}
+
+// OptionIter iterates over parsed options. Next returns the next option or the error
+// that stopped scanning, ok is false when there are no more options.
+type OptionIter interface {
+	Next() (opt *Option, err error, ok bool)
+}
+
+// Iter returns an iterator over options parsed by s.
+func (s *Scanner) Iter() OptionIter {
+	return &scannerIter{s: s}
+}
+
+type scannerIter struct {
+	s *Scanner
+	// Scanner error was returned by Next
+	reported bool
+}
+
+func (it *scannerIter) Next() (*Option, error, bool) {
+	if it.s.Scan() {
+		return it.s.Opt(), nil, true
+	}
+	if err := it.s.Err(); err != nil && !it.reported {
+		it.reported = true
+		return nil, err, true
+	}
+	return nil, nil, false
+}
+
+// Stream scans options in a separate goroutine and sends them to the returned channel.
+// The error that stopped scanning, if any, is sent as the last result. The channel is
+// closed when scanning completes or ctx is cancelled.
+func (s *Scanner) Stream(ctx context.Context) <-chan OptionResult {
+	ch := make(chan OptionResult)
+	go func() {
+		defer close(ch)
+		for s.Scan() {
+			select {
+			case ch <- OptionResult{Option: s.Opt()}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			select {
+			case ch <- OptionResult{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return ch
+}
+
+// Reset rewinds the scanner to the start of the command line and clears all state
+// accumulated by scanning, like parsed options, operands and errors. Scanner settings
+// are preserved.
+func (s *Scanner) Reset() {
+	s.optind = 1
+	s.optpos = 1
+	s.arg = ""
+	s.opt = nil
+	s.err = nil
+	s.terminated = false
+	s.stop = StopNone
+	s.optindex = -1
+	s.options = nil
+	s.indexes = nil
+	s.operands = nil
+	s.noperands = 0
+}
+
+// SetArgs replaces command line arguments and resets the scanner.
+// The first element of argv is treated as the program name, an empty argv is reported
+// as an error through Err. The argv provider set by NewFunc is discarded if it wasn't
+// called yet.
+func (s *Scanner) SetArgs(argv []string) {
+	s.provider = nil
+	s.argv = argv
+	s.base = 0
+	s.progname = ""
+	if len(argv) > 0 {
+		s.progname = path.Base(argv[0])
+	}
+	s.Reset()
+	if len(argv) == 0 {
+		s.err = errEmptyArgv
+	}
+}
+
+// SetTerminator sets the token that terminates option parsing, "--" by default.
+// An empty tok disables terminator handling.
+func (s *Scanner) SetTerminator(tok string) {
+	s.terminator = tok
+}
+
+// SetAllowEmptyArg controls whether an empty option argument, like in -o "", is kept
+// as a present empty string. By default empty arguments are treated as missing.
+func (s *Scanner) SetAllowEmptyArg(allow bool) {
+	s.allowEmptyArg = allow
+}
+
+// SetAllowBundling controls whether several options may be combined in one argv
+// element, like in -ab. It's enabled by default. When disabled, only the last option
+// in an element may be followed by more characters, which become its argument.
+func (s *Scanner) SetAllowBundling(allow bool) {
+	s.bundling = allow
+}
+
+// SetArgTransform sets function fn applied to each remaining argument returned by Args
+// and NextArg. Options and their arguments are not affected.
+func (s *Scanner) SetArgTransform(fn func(string) string) {
+	s.argTransform = fn
+}
+
+// SetValidateUTF8 controls whether option elements of argv that are not valid UTF-8
+// cause InvalidUTF8Error. Operands are not validated.
+func (s *Scanner) SetValidateUTF8(validate bool) {
+	s.validateUTF8 = validate
+}
+
+// SetStdinDash controls whether "-" operands, conventionally meaning stdin, are
+// returned by Args and NextArg as empty strings.
+func (s *Scanner) SetStdinDash(enable bool) {
+	s.stdinDash = enable
+}
+
+// SetUnknownHandler sets the function called for options not listed in optstring
+// instead of reporting InvalidOptionError. If fn returns an error, it's reported by
+// Scan. If fn returns treatAsOperand == true, the rest of the argv element starting
+// with the unknown option becomes an operand, otherwise the option is ignored.
+// The operand is then handled like any other: it stops scanning, is set aside in
+// interspersed mode or is returned in place with the '-' optstring prefix.
+func (s *Scanner) SetUnknownHandler(fn func(opt rune) (treatAsOperand bool, err error)) {
+	s.unknownFunc = fn
+}
+
+// SetInterspersed controls whether options may follow operands. When enabled, operands
+// are set aside and scanning continues with the next argument (GNU getopt permutation),
+// they are then returned by Args before any arguments following the terminator.
+// When disabled (the default) scanning stops at the first operand as required by POSIX.
+// Optstring prefixes take precedence over this setting: with '+' scanning always stops
+// at the first operand and with '-' operands are returned in place by Scan.
+func (s *Scanner) SetInterspersed(interspersed bool) {
+	s.interspersed = interspersed
+}
+
+// SetHonorPosixlyCorrect controls whether the POSIXLY_CORRECT environment variable is
+// honored. When it's set, scanning stops at the first operand even in interspersed mode,
+// like with the '+' optstring prefix. Enabled by default, matching GNU getopt.
+func (s *Scanner) SetHonorPosixlyCorrect(honor bool) {
+	s.honorPosixlyCorrect = honor
+}
+
+// permute returns true if operands are to be set aside and scanning continued.
+func (s *Scanner) permute() bool {
+	if !s.interspersed || s.set.mode&ModePosix != 0 {
+		return false
+	}
+	if s.honorPosixlyCorrect {
+		if _, ok := os.LookupEnv("POSIXLY_CORRECT"); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// SetStrictNoArg controls whether '=' following an option that takes no argument,
+// like in -v=1, causes UnexpectedArgumentError. By default the following characters
+// are treated as more options.
+func (s *Scanner) SetStrictNoArg(strict bool) {
+	s.strictNoArg = strict
+}
+
+// SetPermissive controls whether options not listed in optstring are accepted instead
+// of causing InvalidOptionError. Such options take no argument unless it's attached
+// using '=', like in -x=value. In permissive mode undeclared digit options are
+// accepted too, so negative numbers are no longer recognized as operands.
+func (s *Scanner) SetPermissive(permissive bool) {
+	s.permissive = permissive
+}
+
+// ArgKind returns argument kind (ArgNone, ArgRequired or ArgOptional) of the option
+// opt as declared in optstring. It returns ok == false if opt is not in optstring.
+// Required arguments are reported as ArgRequired even if a ':' prefix makes the
+// scanner treat them as optional.
+func (s *Scanner) ArgKind(opt rune) (kind int, ok bool) {
+	if !isOptionRune(opt) {
+		return 0, false
+	}
+	kind, ok = s.set.kinds[byte(opt)]
+	return kind, ok
+}
+
+// SetVersion sets program version. When set, scanner reports ErrVersionRequested
+// when it encounters --version.
+func (s *Scanner) SetVersion(v string) {
+	s.version = v
+}
+
+// SetVersionShort controls whether scanner also reports ErrVersionRequested when it
+// encounters -V and version was set by SetVersion. -V listed in optstring is always
+// parsed as a regular option.
+func (s *Scanner) SetVersionShort(enable bool) {
+	s.versionShort = enable
+}
+
+// PrintVersion writes program name and version set by SetVersion to w.
+func (s *Scanner) PrintVersion(w io.Writer) {
+	fmt.Fprintf(w, "%s %s\n", s.ProgramName(), s.version)
+}
+
+// SetGreedy makes the option opt collect all following arguments up to the terminator
+// (or the end of the command line) into Option.Args, like find -exec ... ';'.
+// The terminator itself is consumed. SetGreedy ignores opt if it isn't a valid option
+// character.
+func (s *Scanner) SetGreedy(opt rune, terminator string) {
+	if !isOptionRune(opt) {
+		return
+	}
+	if s.greedy == nil {
+		s.greedy = make(map[byte]string)
+	}
+	s.greedy[byte(opt)] = terminator
+}
+
+// SetNoRepeat disallows repeating options opts, repeated option causes
+// DuplicateOptionError. Runes that aren't valid option characters are ignored.
+func (s *Scanner) SetNoRepeat(opts ...rune) {
+	if s.noRepeat == nil {
+		s.noRepeat = make(map[byte]bool)
+	}
+	for _, opt := range opts {
+		if !isOptionRune(opt) {
+			continue
+		}
+		s.noRepeat[byte(opt)] = true
+	}
+}
+
+// SetMaxCount limits the number of times the option opt may be given to n.
+// Exceeding the limit causes TooManyOccurrencesError. SetMaxCount ignores opt if it
+// isn't a valid option character.
+func (s *Scanner) SetMaxCount(opt rune, n int) {
+	if !isOptionRune(opt) {
+		return
+	}
+	if s.maxCount == nil {
+		s.maxCount = make(map[byte]int)
+	}
+	s.maxCount[byte(opt)] = n
+}
+
+// SetValidator sets function fn validating arguments of the option opt. If fn returns
+// an error, scanner reports it wrapped in ValidationError. SetValidator ignores opt if
+// it isn't a valid option character.
+func (s *Scanner) SetValidator(opt rune, fn func(string) error) {
+	if !isOptionRune(opt) {
+		return
+	}
+	if s.validators == nil {
+		s.validators = make(map[byte]func(string) error)
+	}
+	s.validators[byte(opt)] = fn
+}
+
+// CollectInto makes scanner append the argument of the option opt to dst each time
+// the option is parsed with an argument. Within Try, arguments are appended only if
+// fn succeeds. CollectInto ignores opt if it isn't a valid option character.
+func (s *Scanner) CollectInto(opt rune, dst *[]string) {
+	if !isOptionRune(opt) {
+		return
+	}
+	if s.collectors == nil {
+		s.collectors = make(map[byte]*[]string)
+	}
+	s.collectors[byte(opt)] = dst
+}
+
+// AppendOption appends opt and its argument, if any, to dst as command line arguments
+// that s parses back to the same option and returns the extended slice. Required
+// arguments are appended as a separate element and arguments of options declared
+// with "::" are attached using '=', like -z=value. Options set by SetGreedy are
+// followed by the collected arguments and the terminator. Operands returned in place
+// with the '-' optstring prefix are appended as is.
+func (s *Scanner) AppendOption(dst []string, opt *Option) []string {
+	if opt.Opt == 0 {
+		return append(dst, opt.String())
+	}
+	name := "-" + string(opt.Opt)
+	if term, ok := s.greedy[opt.Opt]; ok {
+		dst = append(append(dst, name), opt.Args...)
+		return append(dst, term)
+	}
+	if !opt.HasArg() {
+		return append(dst, name)
+	}
+	kind, ok := s.argKind(opt.Opt)
+	switch {
+	case !ok || s.set.kinds[opt.Opt] == ArgOptional:
+		// undeclared options in permissive mode also accept '='
+		return append(dst, name+"="+*opt.Arg)
+	case kind == ArgRequired:
+		return append(dst, name, *opt.Arg)
+	default:
+		// required argument made optional by the ':' optstring prefix
+		return append(dst, name+*opt.Arg)
+	}
+}
+
+// Options returns all options parsed so far.
+func (s *Scanner) Options() []*Option {
+	return append([]*Option(nil), s.options...)
+}
+
+// OptionIndex returns the argv index of the element containing the option (or the
+// error) returned by the last call to Scan, or -1 if Scan wasn't called yet.
+// Options bundled in one element share the same index.
+func (s *Scanner) OptionIndex() int {
+	if s.optindex < 0 {
+		return s.optindex
+	}
+	return s.optindex - s.base
+}
+
+// OptionIndexes returns the argv indexes of all options parsed so far, parallel to
+// the slice returned by Options.
+func (s *Scanner) OptionIndexes() []int {
+	var res []int
+	for _, i := range s.indexes {
+		res = append(res, i-s.base)
+	}
+	return res
+}
+
+// Occurrences returns the option opt each time it was parsed, in command line order.
+func (s *Scanner) Occurrences(opt rune) []*Option {
+	var res []*Option
+	for _, o := range s.options {
+		if rune(o.Opt) == opt {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// Merge returns the last occurrence of each option parsed by base or override, with
+// options parsed by override taking precedence.
+func Merge(base, override *Scanner) map[rune]*Option {
+	res := make(map[rune]*Option)
+	for _, s := range []*Scanner{base, override} {
+		for _, o := range s.options {
+			res[rune(o.Opt)] = o
+		}
+	}
+	return res
+}
+
+// Duplicates returns options parsed more than once and their counts.
+func (s *Scanner) Duplicates() map[rune]int {
+	counts := make(map[rune]int)
+	for _, o := range s.options {
+		counts[rune(o.Opt)]++
+	}
+	for opt, n := range counts {
+		if n < 2 {
+			delete(counts, opt)
+		}
+	}
+	return counts
+}
+
+// Seen returns true if the option opt was parsed at least once.
+func (s *Scanner) Seen(opt rune) bool {
+	for _, o := range s.options {
+		if rune(o.Opt) == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Arg returns the last argument supplied for the option opt. It returns ok == false
+// if the option wasn't parsed or was never given an argument.
+func (s *Scanner) Arg(opt rune) (arg string, ok bool) {
+	for i := len(s.options) - 1; i >= 0; i-- {
+		if o := s.options[i]; rune(o.Opt) == opt && o.Arg != nil {
+			return *o.Arg, true
+		}
+	}
+	return "", false
+}
+
+// DefinedShort returns option characters declared in optstring in declaration order.
+func (s *Scanner) DefinedShort() []rune {
+	res := make([]rune, len(s.set.opts))
+	for i, opt := range s.set.opts {
+		res[i] = opt.Char
+	}
+	return res
+}
+
+// Err returns the first error encountered by the scanner, if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// ArgsString returns remaining command line arguments shell-quoted and joined with spaces.
+func (s *Scanner) ArgsString() string {
+	args := s.Args()
+	res := make([]string, len(args))
+	for i, arg := range args {
+		res[i] = shellQuote(arg)
+	}
+	return strings.Join(res, " ")
+}
+
+// FormatError returns getopt(3)-style diagnostic message for err prefixed with the
+// program name.
+func (s *Scanner) FormatError(err error) string {
+	var invalid InvalidOptionError
+	var missing MissingArgumentError
+	switch {
+	case errors.As(err, &invalid):
+		return fmt.Sprintf("%s: illegal option -- '%c'", s.ProgramName(), byte(invalid))
+	case errors.As(err, &missing):
+		return fmt.Sprintf("%s: option requires an argument -- '%c'", s.ProgramName(), byte(missing))
+	default:
+		return fmt.Sprintf("%s: %s", s.ProgramName(), err)
+	}
+}
+
+// ProgramName returns basename of argv[0], or the name set by SetProgramName.
 func (s *Scanner) ProgramName() string {
+	if s.prognameOverride != "" {
+		return s.prognameOverride
+	}
+	s.load()
 	return s.progname
 }
 
+// SetProgramName overrides program name derived from argv[0] in FormatError,
+// PrintVersion, etc. An empty name restores the derived one.
+func (s *Scanner) SetProgramName(name string) {
+	s.prognameOverride = name
+}
+
+// isOperand returns true if arg is not an option element.
+func (s *Scanner) isOperand(arg string) bool {
+	if arg == "-" {
+		// lone "-" is an operand, conventionally meaning stdin
+		return true
+	}
+	if len(arg) < 2 || arg[0] != '-' || !isOptionChar(arg[1]) {
+		return true
+	}
+	if c := arg[1]; '0' <= c && c <= '9' && !s.permissive {
+		// undeclared digit option is a negative number operand
+		_, ok := s.argKind(c)
+		return !ok
+	}
+	return false
+}
+
 func isOptionChar(c byte) bool {
 	return ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9')
 }
 
+func isOptionRune(r rune) bool {
+	return 0 <= r && r <= 0xff && isOptionChar(byte(r))
+}
+
+func (s *Scanner) argValue(v string) *string {
+	if s.allowEmptyArg {
+		return &v
+	}
+	return optArg(v)
+}
+
 func optArg(s string) *string {
 	if s != "" {
 		return &s
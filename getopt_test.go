@@ -1,10 +1,21 @@
 package getopt
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func ExampleNewArgv() {
@@ -14,17 +25,16 @@ func ExampleNewArgv() {
 	}
 
 	for scanner.Scan() {
-		opt, err := scanner.Option()
-		if err != nil {
-			panic("error: " + err.Error())
-		}
-
+		opt := scanner.Opt()
 		if opt.HasArg() {
 			fmt.Printf("%s: got option %q with arg %q\n", scanner.ProgramName(), opt.Opt, opt)
 		} else {
 			fmt.Printf("%s: got option %q\n", scanner.ProgramName(), opt.Opt)
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		panic("error: " + err.Error())
+	}
 	fmt.Printf("%s: remaining arguments: %q\n", scanner.ProgramName(), scanner.Args())
 	// Output:
 	// getopt: got option 'b'
@@ -34,6 +44,24 @@ func ExampleNewArgv() {
 	// getopt: remaining arguments: ["-w" "arg1" "arg2"]
 }
 
+func ExampleScanner_Opt() {
+	scanner, err := NewArgv("a:bv", []string{"getopt", "-ba42", "-x", "arg1"})
+	if err != nil {
+		panic("error creating scanner: " + err.Error())
+	}
+
+	for scanner.Scan() {
+		fmt.Printf("%s: got option %q\n", scanner.ProgramName(), scanner.Opt().Opt)
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("%s: %s\n", scanner.ProgramName(), err)
+	}
+	// Output:
+	// getopt: got option 'b'
+	// getopt: got option 'a'
+	// getopt: unknown option: -x
+}
+
 func TestOptionsNoArgs(t *testing.T) {
 	examples := []struct {
 		optstring string
@@ -104,7 +132,11 @@ func TestOptionsNoArgs(t *testing.T) {
 	}
 
 	for i, ex := range examples {
-		actual, errors, _ := parseOptions(t, ex.optstring, ex.argv)
+		actual, errors, remaining := parseOptions(t, ex.optstring, ex.argv)
+		scanned, scanErrors, scanRemaining := scanOptions(t, ex.optstring, ex.argv)
+		if !reflect.DeepEqual(actual, scanned) || !reflect.DeepEqual(errors, scanErrors) || !reflect.DeepEqual(remaining, scanRemaining) {
+			t.Errorf("example %d: Scan/Opt loop results differ from Scan/Option loop", i+1)
+		}
 		if len(errors) > 0 || len(ex.errors) > 0 {
 			if len(errors) > 0 && len(ex.errors) == 0 {
 				t.Errorf("example %d: expected no errors, got\n%s", i+1, dumpErrors(errors))
@@ -256,10 +288,72 @@ func TestOptionsWithArgs(t *testing.T) {
 			[]*Option{{Opt: 'a'}, {Opt: 'b'}, {Opt: 'c'}},
 			nil,
 		},
+		// last bundled option requires an argument and none follows
+		{
+			"ab:",
+			[]string{"getopt", "-ab"},
+			[]*Option{{Opt: 'a'}},
+			[]error{MissingArgumentError('b')},
+		},
+		{
+			":ab:",
+			[]string{"getopt", "-ab"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}},
+			nil,
+		},
+		// optional argument doesn't consume a following option
+		{
+			"a::b",
+			[]string{"getopt", "-a", "-b"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}},
+			nil,
+		},
+		{
+			"a::b:",
+			[]string{"getopt", "-a", "-b", "-x"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b', Arg: optArg("-x")}},
+			nil,
+		},
+		// next bundle starts cleanly after an argument taken from the same element
+		{
+			"ab:cd",
+			[]string{"getopt", "-ab42", "-cd"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b', Arg: optArg("42")}, {Opt: 'c'}, {Opt: 'd'}},
+			nil,
+		},
+		{
+			"ab:cd",
+			[]string{"getopt", "-ab", "42", "-dc", "-ab4"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b', Arg: optArg("42")}, {Opt: 'd'}, {Opt: 'c'}, {Opt: 'a'}, {Opt: 'b', Arg: optArg("4")}},
+			nil,
+		},
+		// optional argument option is the last argv element
+		{
+			"a::",
+			[]string{"getopt", "-a"},
+			[]*Option{{Opt: 'a'}},
+			nil,
+		},
+		{
+			"a::",
+			[]string{"getopt", "-a", "x"},
+			[]*Option{{Opt: 'a', Arg: optArg("x")}},
+			nil,
+		},
+		{
+			"a::",
+			[]string{"getopt", "-ax"},
+			[]*Option{{Opt: 'a', Arg: optArg("x")}},
+			nil,
+		},
 	}
 
 	for i, ex := range examples {
-		actual, errors, _ := parseOptions(t, ex.optstring, ex.argv)
+		actual, errors, remaining := parseOptions(t, ex.optstring, ex.argv)
+		scanned, scanErrors, scanRemaining := scanOptions(t, ex.optstring, ex.argv)
+		if !reflect.DeepEqual(actual, scanned) || !reflect.DeepEqual(errors, scanErrors) || !reflect.DeepEqual(remaining, scanRemaining) {
+			t.Errorf("example %d: Scan/Opt loop results differ from Scan/Option loop", i+1)
+		}
 		if len(errors) > 0 || len(ex.errors) > 0 {
 			if len(errors) > 0 && len(ex.errors) == 0 {
 				t.Errorf("example %d: expected no errors, got\n%s", i+1, dumpErrors(errors))
@@ -323,10 +417,73 @@ func TestOptionsRemainingArgs(t *testing.T) {
 			[]string{"-b", "--", "arg1", "arg2"},
 			nil,
 		},
+		// "--" at argv start
+		{
+			"a",
+			[]string{"getopt", "--"},
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"a",
+			[]string{"getopt", "--", "--"},
+			nil,
+			[]string{"--"},
+			nil,
+		},
+		{
+			"a",
+			[]string{"getopt", "--", "-a"},
+			nil,
+			[]string{"-a"},
+			nil,
+		},
+		// digit options take precedence over negative number operands
+		{
+			"5",
+			[]string{"getopt", "-5", "arg1"},
+			[]*Option{{Opt: '5'}},
+			[]string{"arg1"},
+			nil,
+		},
+		{
+			"a",
+			[]string{"getopt", "-5", "arg1"},
+			nil,
+			[]string{"-5", "arg1"},
+			nil,
+		},
+		{
+			"a5",
+			[]string{"getopt", "-a", "-10"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"-10"},
+			nil,
+		},
+		// lone "-" is an operand
+		{
+			"a",
+			[]string{"getopt", "-a", "-", "file"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"-", "file"},
+			nil,
+		},
+		{
+			"ab",
+			[]string{"getopt", "-", "-a", "-b"},
+			nil,
+			[]string{"-", "-a", "-b"},
+			nil,
+		},
 	}
 
 	for i, ex := range examples {
 		actual, errors, remaining := parseOptions(t, ex.optstring, ex.argv)
+		scanned, scanErrors, scanRemaining := scanOptions(t, ex.optstring, ex.argv)
+		if !reflect.DeepEqual(actual, scanned) || !reflect.DeepEqual(errors, scanErrors) || !reflect.DeepEqual(remaining, scanRemaining) {
+			t.Errorf("example %d: Scan/Opt loop results differ from Scan/Option loop", i+1)
+		}
 		if len(errors) > 0 || len(ex.errors) > 0 {
 			if len(errors) > 0 && len(ex.errors) == 0 {
 				t.Errorf("example %d: expected no errors, got\n%s", i+1, dumpErrors(errors))
@@ -350,22 +507,2870 @@ func TestOptionsRemainingArgs(t *testing.T) {
 	}
 }
 
-func parseOptions(t *testing.T, optstring string, argv []string) ([]*Option, []error, []string) {
-	var options []*Option
-	var errors []error
+func TestTerminator(t *testing.T) {
+	examples := []struct {
+		optstring  string
+		terminator string
+		argv       []string
+		expected   []*Option
+		remaining  []string
+	}{
+		{
+			"ab",
+			"-",
+			[]string{"getopt", "-a", "-", "-b", "arg1"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"-b", "arg1"},
+		},
+		{
+			"ab",
+			"++",
+			[]string{"getopt", "-a", "++", "-b", "arg1"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"-b", "arg1"},
+		},
+		{
+			"ab",
+			"++",
+			[]string{"getopt", "-a", "--", "-b", "arg1"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"--", "-b", "arg1"},
+		},
+		{
+			"ab",
+			"",
+			[]string{"getopt", "-a", "--", "-b", "arg1"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"--", "-b", "arg1"},
+		},
+	}
 
-	scanner, err := NewArgv(optstring, argv)
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetTerminator(ex.terminator)
+
+		var actual []*Option
+		for scanner.Scan() {
+			opt, err := scanner.Option()
+			if err != nil {
+				t.Fatalf("example %d: unexpected error: %s", i+1, err)
+			}
+			actual = append(actual, opt)
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(ex.remaining, scanner.Args()) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(scanner.Args()))
+		}
+	}
+}
+
+func TestAllowEmptyArg(t *testing.T) {
+	examples := []struct {
+		allow    bool
+		argv     []string
+		expected []*Option
+	}{
+		{
+			false,
+			[]string{"getopt", "-o", "", "-v"},
+			[]*Option{{Opt: 'o'}, {Opt: 'v'}},
+		},
+		{
+			true,
+			[]string{"getopt", "-o", "", "-v"},
+			[]*Option{{Opt: 'o', Arg: new(string)}, {Opt: 'v'}},
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("o:v", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetAllowEmptyArg(ex.allow)
+
+		var actual []*Option
+		for scanner.Scan() {
+			opt, err := scanner.Option()
+			if err != nil {
+				t.Fatalf("example %d: unexpected error: %s", i+1, err)
+			}
+			actual = append(actual, opt)
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if actual[0].HasArg() != ex.allow {
+			t.Errorf("example %d: expected HasArg() == %v", i+1, ex.allow)
+		}
+	}
+}
+
+func TestValidateUTF8(t *testing.T) {
+	examples := []struct {
+		validate bool
+		argv     []string
+		expected []*Option
+		err      error
+	}{
+		{
+			false,
+			[]string{"getopt", "-a", "-a\xff"},
+			[]*Option{{Opt: 'a'}, {Opt: 'a'}},
+			InvalidOptionError(0xff),
+		},
+		{
+			true,
+			[]string{"getopt", "-a", "-a\xff"},
+			[]*Option{{Opt: 'a'}},
+			InvalidUTF8Error{Index: 2, Arg: "-a\xff"},
+		},
+		{
+			true,
+			[]string{"getopt", "-ao\xc3"},
+			nil,
+			InvalidUTF8Error{Index: 1, Arg: "-ao\xc3"},
+		},
+		{
+			true,
+			[]string{"getopt", "-oé", "-a", "\xff"},
+			[]*Option{{Opt: 'o', Arg: optArg("é")}, {Opt: 'a'}},
+			nil,
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("ao:", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetValidateUTF8(ex.validate)
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+	}
+
+	if _, err := NewArgv("a\xff", []string{"getopt"}); err == nil {
+		t.Errorf("expected error for invalid UTF-8 optstring")
+	}
+}
+
+func TestStdinDash(t *testing.T) {
+	examples := []struct {
+		enable    bool
+		remaining []string
+	}{
+		{false, []string{"-", "file", "-"}},
+		{true, []string{"", "file", ""}},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("a", []string{"getopt", "-a", "-", "file", "-"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetStdinDash(ex.enable)
+		for scanner.Scan() {
+		}
+		if actual := scanner.Args(); !reflect.DeepEqual(ex.remaining, actual) {
+			t.Errorf("example %d: expected remaining %q, got %q", i+1, ex.remaining, actual)
+		}
+		for _, expected := range ex.remaining {
+			if arg, ok := scanner.NextArg(); !ok || arg != expected {
+				t.Errorf("example %d: expected next argument %q, got %q (ok=%v)", i+1, expected, arg, ok)
+			}
+		}
+	}
+}
+
+func TestArgTransform(t *testing.T) {
+	scanner, err := NewArgv("o:", []string{"getopt", "-o", "out.txt", "a.txt", "b.txt"})
 	if err != nil {
 		t.Fatal(err)
-		return nil, nil, nil
 	}
-	for scanner.Scan() {
-		opt, err := scanner.Option()
+	scanner.SetArgTransform(strings.ToUpper)
+
+	actual, errors, remaining := collectOptions(scanner)
+	if expected := []*Option{{Opt: 'o', Arg: optArg("out.txt")}}; len(errors) > 0 || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected options\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+	if expected := []string{"A.TXT", "B.TXT"}; !reflect.DeepEqual(expected, remaining) {
+		t.Errorf("expected remaining %q, got %q", expected, remaining)
+	}
+	if arg, ok := scanner.NextArg(); !ok || arg != "A.TXT" {
+		t.Errorf("expected next argument A.TXT, got %q (ok=%v)", arg, ok)
+	}
+}
+
+func TestInterspersed(t *testing.T) {
+	examples := []struct {
+		interspersed bool
+		argv         []string
+		expected     []*Option
+		remaining    []string
+	}{
+		{
+			false,
+			[]string{"getopt", "-a", "arg1", "-b", "arg2"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"arg1", "-b", "arg2"},
+		},
+		{
+			true,
+			[]string{"getopt", "-a", "arg1", "-b", "arg2"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}},
+			[]string{"arg1", "arg2"},
+		},
+		{
+			true,
+			[]string{"getopt", "arg1", "-o", "file", "arg2", "--", "-b", "arg3"},
+			[]*Option{{Opt: 'o', Arg: optArg("file")}},
+			[]string{"arg1", "arg2", "-b", "arg3"},
+		},
+		// lone "-" is an operand, not a terminator
+		{
+			true,
+			[]string{"getopt", "-a", "-", "-b", "file"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}},
+			[]string{"-", "file"},
+		},
+		{
+			true,
+			[]string{"getopt", "arg1"},
+			nil,
+			[]string{"arg1"},
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("abo:", ex.argv)
 		if err != nil {
-			errors = append(errors, err)
+			t.Fatal(err)
+		}
+		scanner.SetInterspersed(ex.interspersed)
+
+		actual, errors, remaining := collectOptions(scanner)
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(remaining))
+		}
+	}
+}
+
+func TestHonorPosixlyCorrect(t *testing.T) {
+	examples := []struct {
+		env       bool
+		honor     bool
+		expected  []*Option
+		remaining []string
+	}{
+		{false, true, []*Option{{Opt: 'a'}, {Opt: 'b'}}, []string{"arg1", "arg2"}},
+		{true, true, []*Option{{Opt: 'a'}}, []string{"arg1", "-b", "arg2"}},
+		{true, false, []*Option{{Opt: 'a'}, {Opt: 'b'}}, []string{"arg1", "arg2"}},
+	}
+
+	for i, ex := range examples {
+		if ex.env {
+			t.Setenv("POSIXLY_CORRECT", "1")
 		} else {
-			options = append(options, opt)
+			t.Setenv("POSIXLY_CORRECT", "")
+			os.Unsetenv("POSIXLY_CORRECT")
+		}
+
+		scanner, err := NewArgv("ab", []string{"getopt", "-a", "arg1", "-b", "arg2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetInterspersed(true)
+		scanner.SetHonorPosixlyCorrect(ex.honor)
+
+		actual, errors, remaining := collectOptions(scanner)
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
 		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(remaining))
+		}
+	}
+}
+
+func TestOptStringPrefix(t *testing.T) {
+	examples := []struct {
+		optstring    string
+		interspersed bool
+		argv         []string
+		expected     []*Option
+		remaining    []string
+	}{
+		{
+			"+ab",
+			true,
+			[]string{"getopt", "-a", "arg1", "-b"},
+			[]*Option{{Opt: 'a'}},
+			[]string{"arg1", "-b"},
+		},
+		{
+			"-ab",
+			false,
+			[]string{"getopt", "-a", "arg1", "-b", "--", "-a"},
+			[]*Option{{Opt: 'a'}, {Arg: optArg("arg1")}, {Opt: 'b'}},
+			[]string{"-a"},
+		},
+		{
+			"-:a:",
+			false,
+			[]string{"getopt", "arg1", "-a", "arg2"},
+			[]*Option{{Arg: optArg("arg1")}, {Opt: 'a', Arg: optArg("arg2")}},
+			nil,
+		},
+		{
+			":+a:b",
+			true,
+			[]string{"getopt", "-a", "-b", "arg1", "-a"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}},
+			[]string{"arg1", "-a"},
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetInterspersed(ex.interspersed)
+
+		actual, errors, remaining := collectOptions(scanner)
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(remaining))
+		}
+	}
+}
+
+func TestUnknownHandler(t *testing.T) {
+	errUnknown := errors.New("unknown")
+	examples := []struct {
+		optstring    string
+		interspersed bool
+		argv         []string
+		handler      func(rune) (bool, error)
+		expected     []*Option
+		err          error
+		remaining    []string
+	}{
+		// ignore
+		{
+			"ab", false,
+			[]string{"getopt", "-axb", "-y", "arg"},
+			func(rune) (bool, error) { return false, nil },
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}},
+			nil,
+			[]string{"arg"},
+		},
+		// error
+		{
+			"ab", false,
+			[]string{"getopt", "-a", "-x", "-b"},
+			func(rune) (bool, error) { return false, errUnknown },
+			[]*Option{{Opt: 'a'}},
+			errUnknown,
+			[]string{"-x", "-b"},
+		},
+		// operand stops scanning
+		{
+			"ab", false,
+			[]string{"getopt", "-ax", "-b"},
+			func(rune) (bool, error) { return true, nil },
+			[]*Option{{Opt: 'a'}},
+			nil,
+			[]string{"-x", "-b"},
+		},
+		// operand is set aside in interspersed mode
+		{
+			"ab", true,
+			[]string{"getopt", "-x", "arg1", "-b", "arg2"},
+			func(rune) (bool, error) { return true, nil },
+			[]*Option{{Opt: 'b'}},
+			nil,
+			[]string{"-x", "arg1", "arg2"},
+		},
+		// operand is returned in place
+		{
+			"-ab", false,
+			[]string{"getopt", "-bxy", "-a"},
+			func(rune) (bool, error) { return true, nil },
+			[]*Option{{Opt: 'b'}, {Arg: optArg("-xy")}, {Opt: 'a'}},
+			nil,
+			nil,
+		},
+		// handler decides per option
+		{
+			"ab", false,
+			[]string{"getopt", "-xa", "-yb"},
+			func(opt rune) (bool, error) { return opt == 'y', nil },
+			[]*Option{{Opt: 'a'}},
+			nil,
+			[]string{"-yb"},
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetInterspersed(ex.interspersed)
+		scanner.SetUnknownHandler(ex.handler)
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+		if remaining := scanner.Args(); !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(remaining))
+		}
+	}
+}
+
+func TestStrictNoArg(t *testing.T) {
+	examples := []struct {
+		strict   bool
+		argv     []string
+		expected []*Option
+		err      error
+	}{
+		{
+			false,
+			[]string{"getopt", "-a", "-v=1"},
+			[]*Option{{Opt: 'a'}, {Opt: 'v'}},
+			InvalidOptionError('='),
+		},
+		{
+			true,
+			[]string{"getopt", "-a", "-v=1"},
+			[]*Option{{Opt: 'a'}},
+			UnexpectedArgumentError('v'),
+		},
+		{
+			true,
+			[]string{"getopt", "-av", "-o=1"},
+			[]*Option{{Opt: 'a'}, {Opt: 'v'}, {Opt: 'o', Arg: optArg("=1")}},
+			nil,
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("avo:", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetStrictNoArg(ex.strict)
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+	}
+}
+
+func TestOptionalArgAssignment(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected []*Option
+	}{
+		{[]string{"getopt", "-z"}, []*Option{{Opt: 'z'}}},
+		{[]string{"getopt", "-z", "-a"}, []*Option{{Opt: 'z'}, {Opt: 'a'}}},
+		{[]string{"getopt", "-z=5"}, []*Option{{Opt: 'z', Arg: optArg("5")}}},
+		{[]string{"getopt", "-az=5"}, []*Option{{Opt: 'a'}, {Opt: 'z', Arg: optArg("5")}}},
+		{[]string{"getopt", "-z=a=b"}, []*Option{{Opt: 'z', Arg: optArg("a=b")}}},
+		{[]string{"getopt", "-z="}, []*Option{{Opt: 'z', Arg: new(string)}}},
+		{[]string{"getopt", "-z5"}, []*Option{{Opt: 'z', Arg: optArg("5")}}},
+		// required arguments keep '=' as part of the value
+		{[]string{"getopt", "-o=5"}, []*Option{{Opt: 'o', Arg: optArg("=5")}}},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("az::o:", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, errors, _ := collectOptions(scanner)
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+	}
+}
+
+func TestPermissive(t *testing.T) {
+	examples := []struct {
+		optstring string
+		argv      []string
+		expected  []*Option
+		remaining []string
+	}{
+		{
+			"",
+			[]string{"getopt", "-a", "-xyz", "arg1"},
+			[]*Option{{Opt: 'a'}, {Opt: 'x'}, {Opt: 'y'}, {Opt: 'z'}},
+			[]string{"arg1"},
+		},
+		{
+			"o:",
+			[]string{"getopt", "-vo", "file", "-q=1", "-D=", "arg1"},
+			[]*Option{{Opt: 'v'}, {Opt: 'o', Arg: optArg("file")}, {Opt: 'q', Arg: optArg("1")}, {Opt: 'D'}},
+			[]string{"arg1"},
+		},
+		{
+			"",
+			[]string{"getopt", "-ab=c=d"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b', Arg: optArg("c=d")}},
+			nil,
+		},
+		{
+			"a",
+			[]string{"getopt", "-5", "-a9", "arg1"},
+			[]*Option{{Opt: '5'}, {Opt: 'a'}, {Opt: '9'}},
+			[]string{"arg1"},
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetPermissive(true)
+
+		var actual []*Option
+		for scanner.Scan() {
+			opt, err := scanner.Option()
+			if err != nil {
+				t.Fatalf("example %d: unexpected error: %s", i+1, err)
+			}
+			actual = append(actual, opt)
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(ex.remaining, scanner.Args()) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(scanner.Args()))
+		}
+	}
+}
+
+func TestArgKind(t *testing.T) {
+	examples := []struct {
+		optstring string
+		opt       rune
+		kind      int
+		ok        bool
+	}{
+		{"a:bz::v", 'a', ArgRequired, true},
+		{"a:bz::v", 'b', ArgNone, true},
+		{"a:bz::v", 'z', ArgOptional, true},
+		{"a:bz::v", 'v', ArgNone, true},
+		{"a:bz::v", 'x', 0, false},
+		{"a:bz::v", ':', 0, false},
+		{":a:bz::v", 'a', ArgRequired, true},
+		{":a:bz::v", 'b', ArgNone, true},
+		{":a:bz::v", 'z', ArgOptional, true},
+		{":a:", 'a', ArgRequired, true},
+		{"a:bz::v", 'a' + 256, 0, false},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, []string{"getopt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kind, ok := scanner.ArgKind(ex.opt)
+		if kind != ex.kind || ok != ex.ok {
+			t.Errorf("example %d: expected (%d, %v), got (%d, %v)", i+1, ex.kind, ex.ok, kind, ok)
+		}
+	}
+}
+
+func TestNewArgvEmpty(t *testing.T) {
+	for i, argv := range [][]string{nil, {}} {
+		if _, err := NewArgv("ab", argv); err == nil {
+			t.Errorf("example %d: expected error for argv %q", i+1, argv)
+		}
+	}
+}
+
+func TestSeen(t *testing.T) {
+	scanner, err := NewArgv("a:bcv", []string{"getopt", "-v", "-cb", "-a", "x", "arg1", "-z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scanner.Scan() {
+	}
+
+	for _, opt := range []rune{'a', 'b', 'c', 'v'} {
+		if !scanner.Seen(opt) {
+			t.Errorf("expected option %q to be seen", opt)
+		}
+	}
+	for _, opt := range []rune{'x', 'z'} {
+		if scanner.Seen(opt) {
+			t.Errorf("expected option %q not to be seen", opt)
+		}
+	}
+}
+
+func TestAllowBundling(t *testing.T) {
+	examples := []struct {
+		allow    bool
+		argv     []string
+		expected []*Option
+		err      error
+	}{
+		{
+			true,
+			[]string{"getopt", "-ab", "-ofile"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}, {Opt: 'o', Arg: optArg("file")}},
+			nil,
+		},
+		{
+			false,
+			[]string{"getopt", "-ab", "-ofile"},
+			nil,
+			BundledOptionError('a'),
+		},
+		{
+			false,
+			[]string{"getopt", "-a", "-b", "-ofile", "-o", "file"},
+			[]*Option{{Opt: 'a'}, {Opt: 'b'}, {Opt: 'o', Arg: optArg("file")}, {Opt: 'o', Arg: optArg("file")}},
+			nil,
+		},
+		{
+			false,
+			[]string{"getopt", "-a", "-bo", "file"},
+			[]*Option{{Opt: 'a'}},
+			BundledOptionError('b'),
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("abo:", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetAllowBundling(ex.allow)
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+	}
+}
+
+func TestMaxCount(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected []*Option
+		err      error
+	}{
+		{
+			[]string{"getopt", "-o", "file", "-v", "-v", "-v"},
+			[]*Option{{Opt: 'o', Arg: optArg("file")}, {Opt: 'v'}, {Opt: 'v'}, {Opt: 'v'}},
+			nil,
+		},
+		{
+			[]string{"getopt", "-o", "file", "-vofile2"},
+			[]*Option{{Opt: 'o', Arg: optArg("file")}, {Opt: 'v'}},
+			TooManyOccurrencesError{Opt: 'o', Max: 1},
+		},
+		{
+			[]string{"getopt", "-vvvv"},
+			[]*Option{{Opt: 'v'}, {Opt: 'v'}, {Opt: 'v'}},
+			TooManyOccurrencesError{Opt: 'v', Max: 3},
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("o:v", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetMaxCount('o', 1)
+		scanner.SetMaxCount('v', 3)
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	examples := []struct {
+		argv []string
+		name string
+		args []string
+	}{
+		{[]string{"prog", "-v", "--", "ls", "-la"}, "ls", []string{"-la"}},
+		{[]string{"prog", "-v", "ls", "-la"}, "ls", []string{"-la"}},
+		{[]string{"prog", "--", "--", "x"}, "--", []string{"x"}},
+		{[]string{"prog", "-v", "ls"}, "ls", []string{}},
+		{[]string{"prog", "-v", "--"}, "", nil},
+		{[]string{"prog"}, "", nil},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("v", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for scanner.Scan() {
+		}
+		name, args := scanner.CommandArgs()
+		if name != ex.name || !reflect.DeepEqual(ex.args, args) {
+			t.Errorf("example %d: expected %q %q, got %q %q", i+1, ex.name, ex.args, name, args)
+		}
+	}
+
+	// terminator not consumed by Scan
+	scanner, err := NewArgv("v", []string{"prog", "--", "ls", "-la"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, args := scanner.CommandArgs(); name != "ls" || !reflect.DeepEqual([]string{"-la"}, args) {
+		t.Errorf("expected %q %q, got %q %q", "ls", []string{"-la"}, name, args)
+	}
+}
+
+func TestOperandCount(t *testing.T) {
+	examples := []struct {
+		optstring    string
+		interspersed bool
+		argv         []string
+		expected     int
+	}{
+		{"ab", true, []string{"getopt", "arg1", "-a", "arg2", "-b", "arg3"}, 3},
+		{"ab", true, []string{"getopt", "arg1", "arg2", "-a", "--", "arg3"}, 2},
+		{"ab", true, []string{"getopt", "-a", "-b"}, 0},
+		{"ab", false, []string{"getopt", "-a", "arg1", "-b", "arg2"}, 0},
+		{"-ab", false, []string{"getopt", "arg1", "-a", "arg2"}, 2},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetInterspersed(ex.interspersed)
+		for scanner.Scan() {
+		}
+		if actual := scanner.OperandCount(); actual != ex.expected {
+			t.Errorf("example %d: expected %d operands, got %d", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		min, max int
+		err      bool
+	}{
+		{[]string{"cp", "-v", "src", "dst"}, 2, 2, false},
+		{[]string{"cp", "-v", "src"}, 2, 2, true},
+		{[]string{"cp", "-v", "src", "dst", "extra"}, 2, 2, true},
+		{[]string{"cp", "src", "dst", "extra"}, 2, -1, false},
+		{[]string{"cp"}, 0, -1, false},
+		{[]string{"cp", "-v"}, 1, -1, true},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("v", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for scanner.Scan() {
+		}
+		if err := scanner.CheckArgs(ex.min, ex.max); (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+		}
+	}
+}
+
+func TestNextArg(t *testing.T) {
+	scanner, err := NewArgv("a:bc", []string{"getopt", "-b", "cmd", "-a", "x", "file1", "-c", "file2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for {
+		for scanner.Scan() {
+			opt, err := scanner.Option()
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = append(actual, "-"+string(opt.Opt))
+		}
+		arg, ok := scanner.NextArg()
+		if !ok {
+			break
+		}
+		actual = append(actual, arg)
+	}
+	expected := []string{"-b", "cmd", "-a", "file1", "-c", "file2"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	if _, ok := scanner.NextArg(); ok {
+		t.Errorf("expected no more arguments")
+	}
+
+	scanner, err = NewArgv("a", []string{"getopt", "arg1", "-a", "arg2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetInterspersed(true)
+	for scanner.Scan() {
+	}
+	for _, expected := range []string{"arg1", "arg2"} {
+		if arg, ok := scanner.NextArg(); !ok || arg != expected {
+			t.Errorf("expected %q, got %q (ok=%v)", expected, arg, ok)
+		}
+	}
+	if _, ok := scanner.NextArg(); ok {
+		t.Errorf("expected no more arguments")
+	}
+}
+
+func TestDrainArgs(t *testing.T) {
+	scanner, err := NewArgv("a", []string{"getopt", "-a", "arg1", "-a", "--", "arg2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	scanner.SetOperandFunc(func(arg string) {
+		actual = append(actual, arg)
+	})
+	for scanner.Scan() {
+	}
+	scanner.DrainArgs()
+
+	if expected := []string{"arg1", "-a", "--", "arg2"}; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	if remaining := scanner.Args(); remaining != nil {
+		t.Errorf("expected no remaining arguments, got %q", remaining)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected []string
+		err      error
+	}{
+		{
+			[]string{"getopt", "arg1", "-a", "x", "arg2", "-bc", "-", "arg3"},
+			[]string{"arg1", "-a x", "arg2", "-b", "-c", "-", "arg3"},
+			nil,
+		},
+		{
+			[]string{"getopt", "-b", "arg1", "--", "-c", "--", "arg2"},
+			[]string{"-b", "arg1", "-c", "--", "arg2"},
+			nil,
+		},
+		{
+			[]string{"getopt", "arg1", "-z", "arg2"},
+			[]string{"arg1"},
+			InvalidOptionError('z'),
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("a:bc", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actual []string
+		err = scanner.Walk(func(opt *Option) error {
+			s := "-" + string(opt.Opt)
+			if opt.HasArg() {
+				s += " " + opt.String()
+			}
+			actual = append(actual, s)
+			return nil
+		}, func(arg string) error {
+			actual = append(actual, arg)
+			return nil
+		})
+		if err != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, err)
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestWalkStickyError(t *testing.T) {
+	scanner, err := NewArgv("a", []string{"getopt", "-a", "-z", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scanner.Scan() {
+	}
+
+	var actual []string
+	err = scanner.Walk(func(opt *Option) error {
+		actual = append(actual, "-"+string(opt.Opt))
+		return nil
+	}, func(arg string) error {
+		actual = append(actual, arg)
+		return nil
+	})
+	if err != InvalidOptionError('z') {
+		t.Errorf("expected error %v, got %v", InvalidOptionError('z'), err)
+	}
+	if len(actual) > 0 {
+		t.Errorf("expected no callbacks, got %q", actual)
+	}
+}
+
+func TestWalkUnknownHandler(t *testing.T) {
+	scanner, err := NewArgv("ab", []string{"getopt", "-a", "-x", "op", "-bxy", "-b", "--", "-z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetUnknownHandler(func(rune) (bool, error) { return true, nil })
+
+	var actual []string
+	err = scanner.Walk(func(opt *Option) error {
+		actual = append(actual, "opt "+string(opt.Opt))
+		return nil
+	}, func(arg string) error {
+		actual = append(actual, "operand "+arg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"opt a", "operand -x", "operand op", "opt b", "operand -xy", "opt b", "operand -z"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestWalkCallbackError(t *testing.T) {
+	scanner, err := NewArgv("a", []string{"getopt", "-a", "arg1", "arg2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errStop := fmt.Errorf("stop")
+	var operands []string
+	err = scanner.Walk(func(opt *Option) error {
+		return nil
+	}, func(arg string) error {
+		operands = append(operands, arg)
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("expected error %v, got %v", errStop, err)
+	}
+	if !reflect.DeepEqual([]string{"arg1"}, operands) {
+		t.Errorf("expected walk to stop after the first operand, got %q", operands)
+	}
+	if !reflect.DeepEqual([]string{"arg2"}, scanner.Args()) {
+		t.Errorf("expected remaining arguments [arg2], got %q", scanner.Args())
+	}
+}
+
+func TestIter(t *testing.T) {
+	scanner, err := NewArgv("ab:", []string{"getopt", "-a", "-bfoo", "-z", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := scanner.Iter()
+	var options []*Option
+	var errors []error
+	for {
+		opt, err, ok := it.Next()
+		if !ok {
+			break
+		}
+		if err != nil {
+			errors = append(errors, err)
+		} else {
+			options = append(options, opt)
+		}
+	}
+
+	expected := []*Option{{Opt: 'a'}, {Opt: 'b', Arg: optArg("foo")}}
+	if !reflect.DeepEqual(expected, options) {
+		t.Errorf("expected options\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(options))
+	}
+	if expected := []error{InvalidOptionError('z')}; !reflect.DeepEqual(expected, errors) {
+		t.Errorf("expected errors\n%s\ngot\n%s", dumpErrors(expected), dumpErrors(errors))
+	}
+	if _, _, ok := it.Next(); ok {
+		t.Error("expected exhausted iterator")
+	}
+}
+
+func TestStream(t *testing.T) {
+	scanner, err := NewArgv("a:b", []string{"getopt", "-ba42", "-x", "arg1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []*Option
+	var errors []error
+	for res := range scanner.Stream(context.Background()) {
+		if res.Err != nil {
+			errors = append(errors, res.Err)
+		} else {
+			actual = append(actual, res.Option)
+		}
+	}
+	expected := []*Option{{Opt: 'b'}, {Opt: 'a', Arg: optArg("42")}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected options\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+	if expected := []error{InvalidOptionError('x')}; !reflect.DeepEqual(expected, errors) {
+		t.Errorf("expected errors\n%s\ngot\n%s", dumpErrors(expected), dumpErrors(errors))
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	scanner, err := NewArgv("ab", []string{"getopt", "-a", "-b", "-a", "-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := scanner.Stream(ctx)
+	if res := <-ch; res.Err != nil || res.Option.Opt != 'a' {
+		t.Errorf("unexpected first result: %+v", res)
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+func TestVersion(t *testing.T) {
+	examples := []struct {
+		optstring string
+		version   string
+		short     bool
+		argv      []string
+		expected  []*Option
+		err       error
+	}{
+		{
+			"a",
+			"1.0",
+			false,
+			[]string{"getopt", "-a", "--version"},
+			[]*Option{{Opt: 'a'}},
+			ErrVersionRequested,
+		},
+		{
+			"a",
+			"1.0",
+			true,
+			[]string{"getopt", "-aV"},
+			[]*Option{{Opt: 'a'}},
+			ErrVersionRequested,
+		},
+		// -V is opt-in
+		{
+			"a",
+			"1.0",
+			false,
+			[]string{"getopt", "-aV"},
+			[]*Option{{Opt: 'a'}},
+			InvalidOptionError('V'),
+		},
+		// caller defined their own -V
+		{
+			"aV",
+			"1.0",
+			true,
+			[]string{"getopt", "-a", "-V"},
+			[]*Option{{Opt: 'a'}, {Opt: 'V'}},
+			nil,
+		},
+		// version is not set
+		{
+			"a",
+			"",
+			true,
+			[]string{"getopt", "-a", "-V"},
+			[]*Option{{Opt: 'a'}},
+			InvalidOptionError('V'),
+		},
+		{
+			"a",
+			"",
+			false,
+			[]string{"getopt", "-a", "--version"},
+			[]*Option{{Opt: 'a'}},
+			nil,
+		},
+		// --version after operand or terminator is an operand
+		{
+			"a",
+			"1.0",
+			false,
+			[]string{"getopt", "-a", "--", "--version"},
+			[]*Option{{Opt: 'a'}},
+			nil,
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetVersion(ex.version)
+		scanner.SetVersionShort(ex.short)
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+	}
+
+	scanner, err := NewArgv("a", []string{"/usr/bin/getopt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetVersion("1.2.3")
+	var b strings.Builder
+	scanner.PrintVersion(&b)
+	if expected := "getopt 1.2.3\n"; b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestOptions(t *testing.T) {
+	scanner, err := NewArgv("a:bv", []string{"getopt", "-v", "-ba42", "arg1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scanner.Options()) != 0 {
+		t.Errorf("expected no options before scanning, got\n%s", dumpOptions(scanner.Options()))
+	}
+	expected := []*Option{{Opt: 'v'}, {Opt: 'b'}, {Opt: 'a', Arg: optArg("42")}}
+	for n := 1; scanner.Scan(); n++ {
+		if actual := scanner.Options(); !reflect.DeepEqual(expected[:n], actual) {
+			t.Errorf("step %d: expected options\n%s\ngot\n%s", n, dumpOptions(expected[:n]), dumpOptions(actual))
+		}
+	}
+
+	snapshot := scanner.Options()
+	snapshot[0] = nil
+	if !reflect.DeepEqual(expected, scanner.Options()) {
+		t.Errorf("expected Options to return a copy")
+	}
+}
+
+func TestGreedy(t *testing.T) {
+	examples := []struct {
+		argv      []string
+		expected  []*Option
+		remaining []string
+	}{
+		{
+			[]string{"getopt", "-v", "-x", "a", "b", "c", ";", "-v", "arg1"},
+			[]*Option{{Opt: 'v'}, {Opt: 'x', Args: []string{"a", "b", "c"}}, {Opt: 'v'}},
+			[]string{"arg1"},
+		},
+		{
+			[]string{"getopt", "-vxa", "-b", "--", ";"},
+			[]*Option{{Opt: 'v'}, {Opt: 'x', Args: []string{"a", "-b", "--"}}},
+			nil,
+		},
+		{
+			[]string{"getopt", "-x", ";", "arg1"},
+			[]*Option{{Opt: 'x'}},
+			[]string{"arg1"},
+		},
+		{
+			[]string{"getopt", "-x", "a", "b"},
+			[]*Option{{Opt: 'x', Args: []string{"a", "b"}}},
+			nil,
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("vx", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetGreedy('x', ";")
+
+		actual, errors, remaining := collectOptions(scanner)
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options %+v, got %+v", i+1, ex.expected, actual)
+		}
+		if !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining %q, got %q", i+1, ex.remaining, remaining)
+		}
+	}
+}
+
+func TestSettersIgnoreInvalidRune(t *testing.T) {
+	scanner, err := NewArgv("a:", []string{"getopt", "-a", "x", "-a", "y", "arg1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collected []string
+	scanner.SetGreedy('a'+256, ";")
+	scanner.SetNoRepeat('a' + 256)
+	scanner.SetMaxCount('a'+256, 1)
+	scanner.SetValidator('a'+256, func(string) error { return errors.New("invalid") })
+	scanner.CollectInto('a'+256, &collected)
+
+	actual, errs, remaining := collectOptions(scanner)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors\n%s", dumpErrors(errs))
+	}
+	expected := []*Option{{Opt: 'a', Arg: optArg("x")}, {Opt: 'a', Arg: optArg("y")}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected options %+v, got %+v", expected, actual)
+	}
+	if !reflect.DeepEqual([]string{"arg1"}, remaining) {
+		t.Errorf("expected remaining [\"arg1\"], got %q", remaining)
+	}
+	if collected != nil {
+		t.Errorf("expected nothing collected, got %q", collected)
+	}
+}
+
+func TestNoRepeat(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected []*Option
+		err      error
+	}{
+		{
+			[]string{"getopt", "-o", "file", "-I", "a", "-I", "b"},
+			[]*Option{{Opt: 'o', Arg: optArg("file")}, {Opt: 'I', Arg: optArg("a")}, {Opt: 'I', Arg: optArg("b")}},
+			nil,
+		},
+		{
+			[]string{"getopt", "-o", "file", "-I", "a", "-ofile2"},
+			[]*Option{{Opt: 'o', Arg: optArg("file")}, {Opt: 'I', Arg: optArg("a")}},
+			DuplicateOptionError('o'),
+		},
+		{
+			[]string{"getopt", "-qq"},
+			[]*Option{{Opt: 'q'}},
+			DuplicateOptionError('q'),
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("o:I:q", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetNoRepeat('o', 'q')
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+	}
+}
+
+func TestValidator(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected []*Option
+		err      bool
+	}{
+		{
+			[]string{"getopt", "-p", "8080", "-v"},
+			[]*Option{{Opt: 'p', Arg: optArg("8080")}, {Opt: 'v'}},
+			false,
+		},
+		{
+			[]string{"getopt", "-v", "-phttp", "-v"},
+			[]*Option{{Opt: 'v'}},
+			true,
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("p:v", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetValidator('p', func(arg string) error {
+			_, err := strconv.Atoi(arg)
+			return err
+		})
+
+		var actual []*Option
+		for scanner.Scan() {
+			actual = append(actual, scanner.Opt())
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		var verr ValidationError
+		if ex.err && (!errors.As(scanner.Err(), &verr) || verr.Opt != 'p' || !errors.Is(scanner.Err(), strconv.ErrSyntax)) {
+			t.Errorf("example %d: expected validation error for -p, got %v", i+1, scanner.Err())
+		}
+		if !ex.err && scanner.Err() != nil {
+			t.Errorf("example %d: unexpected error: %v", i+1, scanner.Err())
+		}
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected string
+	}{
+		{[]string{"/bin/prog", "-a"}, "prog: option requires an argument -- 'a'"},
+		{[]string{"/bin/prog", "-z"}, "prog: illegal option -- 'z'"},
+		{[]string{"/bin/prog", "-a1", "-a2"}, "prog: option -a may be given at most 1 time(s)"},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("a:", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetMaxCount('a', 1)
+		for scanner.Scan() {
+		}
+		if actual := scanner.FormatError(scanner.Err()); actual != ex.expected {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestSetProgramName(t *testing.T) {
+	scanner, err := NewArgv("a:", []string{"/usr/libexec/prog-impl", "-z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetProgramName("prog")
+	scanner.SetVersion("1.0")
+	for scanner.Scan() {
+	}
+
+	if expected, actual := "prog", scanner.ProgramName(); actual != expected {
+		t.Errorf("expected program name %q, got %q", expected, actual)
+	}
+	if expected, actual := "prog: illegal option -- 'z'", scanner.FormatError(scanner.Err()); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	var buf strings.Builder
+	scanner.PrintVersion(&buf)
+	if expected, actual := "prog 1.0\n", buf.String(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+
+	scanner.SetProgramName("")
+	if expected, actual := "prog-impl", scanner.ProgramName(); actual != expected {
+		t.Errorf("expected program name %q, got %q", expected, actual)
+	}
+}
+
+func TestOptionIndex(t *testing.T) {
+	scanner, err := NewArgv("a:bc", []string{"getopt", "-bc", "-a", "1", "-a2", "-b", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := scanner.OptionIndex(); actual != -1 {
+		t.Errorf("expected index -1 before scanning, got %d", actual)
+	}
+
+	expected := []int{1, 1, 2, 4, 5}
+	var actual []int
+	for scanner.Scan() {
+		actual = append(actual, scanner.OptionIndex())
+	}
+	if scanner.Err() != nil {
+		t.Fatalf("unexpected error: %v", scanner.Err())
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected indexes %v, got %v", expected, actual)
+	}
+	if actual := scanner.OptionIndexes(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected option indexes %v, got %v", expected, actual)
+	}
+}
+
+func TestArg(t *testing.T) {
+	scanner, err := NewArgv("o:vz::", []string{"getopt", "-o", "first", "-v", "-ofile", "-zfoo", "-z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scanner.Scan() {
+	}
+
+	examples := []struct {
+		opt      rune
+		expected string
+		ok       bool
+	}{
+		{'o', "file", true},
+		{'z', "foo", true},
+		{'v', "", false},
+		{'q', "", false},
+	}
+	for i, ex := range examples {
+		actual, ok := scanner.Arg(ex.opt)
+		if actual != ex.expected || ok != ex.ok {
+			t.Errorf("example %d: expected (%q, %t), got (%q, %t)", i+1, ex.expected, ex.ok, actual, ok)
+		}
+	}
+}
+
+func TestCollectInto(t *testing.T) {
+	scanner, err := NewArgv("I:vz::", []string{"cc", "-I", "include", "-vI/usr/include", "-z", "-zfoo", "-I", "src", "main.c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	includes := []string{"default"}
+	var z []string
+	scanner.CollectInto('I', &includes)
+	scanner.CollectInto('z', &z)
+	for scanner.Scan() {
+	}
+	if scanner.Err() != nil {
+		t.Fatalf("unexpected error: %v", scanner.Err())
+	}
+
+	if expected := []string{"default", "include", "/usr/include", "src"}; !reflect.DeepEqual(expected, includes) {
+		t.Errorf("expected %q, got %q", expected, includes)
+	}
+	if expected := []string{"foo"}; !reflect.DeepEqual(expected, z) {
+		t.Errorf("expected %q, got %q", expected, z)
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	scanner, err := NewArgv("z::v", []string{"getopt", "-z", "-zfoo", "-v", "-z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scanner.Scan() {
+	}
+
+	expected := []*Option{{Opt: 'z'}, {Opt: 'z', Arg: optArg("foo")}, {Opt: 'z'}}
+	actual := scanner.Occurrences('z')
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected occurrences\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+	for i, hasArg := range []bool{false, true, false} {
+		if actual[i].HasArg() != hasArg {
+			t.Errorf("occurrence %d: expected HasArg() == %v", i+1, hasArg)
+		}
+	}
+	if actual := scanner.Occurrences('x'); actual != nil {
+		t.Errorf("expected no occurrences, got\n%s", dumpOptions(actual))
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected map[rune]int
+	}{
+		{[]string{"getopt", "-v", "-a", "x", "-vv", "-a", "y"}, map[rune]int{'v': 3, 'a': 2}},
+		{[]string{"getopt", "-v", "-a", "x", "-b"}, map[rune]int{}},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("a:bv", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for scanner.Scan() {
+		}
+		if actual := scanner.Duplicates(); !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base, err := NewArgv("o:vq", []string{"getopt", "-o", "a.txt", "-v", "-ob.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	override, err := NewArgv("o:vq", []string{"getopt", "-q", "-o", "c.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for base.Scan() {
+	}
+	for override.Scan() {
+	}
+
+	expected := map[rune]*Option{
+		'o': {Opt: 'o', Arg: optArg("c.txt")},
+		'v': {Opt: 'v'},
+		'q': {Opt: 'q'},
+	}
+	if actual := Merge(base, override); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestReset(t *testing.T) {
+	count := func(scanner *Scanner, opt rune) int {
+		n := 0
+		for _, o := range scanner.Options() {
+			if rune(o.Opt) == opt {
+				n++
+			}
+		}
+		return n
+	}
+
+	scanner, err := NewArgv("a:v", []string{"getopt", "-vvv", "-a", "x", "-z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scanner.Scan() {
+	}
+	if count(scanner, 'v') != 3 || !scanner.Seen('a') || scanner.Err() == nil {
+		t.Fatalf("unexpected state after the first parse")
+	}
+
+	scanner.SetArgs([]string{"prog", "-v", "arg1"})
+	actual, errors, remaining := collectOptions(scanner)
+	if count(scanner, 'v') != 1 || scanner.Seen('a') || len(errors) > 0 || scanner.Err() != nil {
+		t.Errorf("expected only the second parse results, got\n%s\n%s", dumpOptions(actual), dumpErrors(errors))
+	}
+	if !reflect.DeepEqual([]string{"arg1"}, remaining) || scanner.ProgramName() != "prog" {
+		t.Errorf("unexpected remaining arguments %q or program name %s", remaining, scanner.ProgramName())
+	}
+
+	scanner.Reset()
+	actual, _, _ = collectOptions(scanner)
+	if expected := []*Option{{Opt: 'v'}}; !reflect.DeepEqual(expected, actual) || count(scanner, 'v') != 1 {
+		t.Errorf("expected options after Reset\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+}
+
+func TestDefinedShort(t *testing.T) {
+	examples := []struct {
+		optstring string
+		expected  []rune
+	}{
+		{"a:bz::v", []rune{'a', 'b', 'z', 'v'}},
+		{"+:Ac5", []rune{'A', 'c', '5'}},
+		{"", []rune{}},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, []string{"getopt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := scanner.DefinedShort(); !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestEmptyOptString(t *testing.T) {
+	examples := []struct {
+		argv      []string
+		err       error
+		remaining []string
+	}{
+		{[]string{"prog", "x", "y"}, nil, []string{"x", "y"}},
+		{[]string{"prog", "--", "-a"}, nil, []string{"-a"}},
+		{[]string{"prog", "-", "x"}, nil, []string{"-", "x"}},
+		{[]string{"prog"}, nil, nil},
+		{[]string{"prog", "-a", "x"}, InvalidOptionError('a'), []string{"-a", "x"}},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var options []*Option
+		for scanner.Scan() {
+			options = append(options, scanner.Opt())
+		}
+		if len(options) > 0 {
+			t.Errorf("example %d: unexpected options\n%s", i+1, dumpOptions(options))
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+		if remaining := scanner.Args(); !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(remaining))
+		}
+	}
+}
+
+func TestNewArgs(t *testing.T) {
+	examples := []struct {
+		args      []string
+		expected  []*Option
+		remaining []string
+	}{
+		{[]string{"-a", "-b"}, []*Option{{Opt: 'a'}, {Opt: 'b'}}, nil},
+		{[]string{"-ab", "arg"}, []*Option{{Opt: 'a'}, {Opt: 'b'}}, []string{"arg"}},
+		{[]string{"arg", "-a"}, nil, []string{"arg", "-a"}},
+		{nil, nil, nil},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgs("ab", ex.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, errors, remaining := collectOptions(scanner)
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(remaining))
+		}
+	}
+}
+
+func TestNewArgsIndexes(t *testing.T) {
+	scanner, err := NewArgs("ab:", []string{"-a", "-bx", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{0, 1}
+	var actual []int
+	for scanner.Scan() {
+		actual = append(actual, scanner.OptionIndex())
+	}
+	if scanner.Err() != nil {
+		t.Fatalf("unexpected error: %v", scanner.Err())
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected indexes %v, got %v", expected, actual)
+	}
+	if actual := scanner.OptionIndexes(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected option indexes %v, got %v", expected, actual)
+	}
+
+	scanner, err = NewArgs("a", []string{"-a", "-a\xff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetValidateUTF8(true)
+	for scanner.Scan() {
+	}
+	var uerr InvalidUTF8Error
+	if !errors.As(scanner.Err(), &uerr) || uerr.Index != 1 {
+		t.Errorf("expected InvalidUTF8Error at index 1, got %v", scanner.Err())
+	}
+}
+
+func TestNewFunc(t *testing.T) {
+	calls := 0
+	argv := []string{"getopt", "-a"}
+	scanner, err := NewFunc("ab", func() []string {
+		calls++
+		return argv
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected provider not to be called before Scan")
+	}
+
+	// command line is finalized after the scanner is created
+	argv = append(argv, "-b", "arg1")
+	actual, errors, remaining := collectOptions(scanner)
+	if calls != 1 {
+		t.Errorf("expected provider to be called once, got %d calls", calls)
+	}
+	if expected := []*Option{{Opt: 'a'}, {Opt: 'b'}}; len(errors) > 0 || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected options\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+	if !reflect.DeepEqual([]string{"arg1"}, remaining) {
+		t.Errorf("unexpected remaining arguments %q", remaining)
+	}
+
+	scanner, err = NewFunc("ab", func() []string { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanner.Scan() || scanner.Err() == nil {
+		t.Errorf("expected error for empty argv")
+	}
+}
+
+func TestSetArgsEmpty(t *testing.T) {
+	scanner, err := NewArgv("a", []string{"getopt", "-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetArgs(nil)
+	if scanner.HasOption() || scanner.Scan() {
+		t.Error("expected no options")
+	}
+	if scanner.Err() != errEmptyArgv {
+		t.Errorf("expected error %v, got %v", errEmptyArgv, scanner.Err())
+	}
+	if err := scanner.Walk(func(*Option) error { return nil }, func(string) error { return nil }); err != errEmptyArgv {
+		t.Errorf("expected Walk error %v, got %v", errEmptyArgv, err)
+	}
+
+	scanner.SetArgs([]string{"getopt", "-a"})
+	if !scanner.Scan() || scanner.Err() != nil {
+		t.Errorf("expected option after SetArgs, got error %v", scanner.Err())
+	}
+}
+
+func TestNewFuncSetArgs(t *testing.T) {
+	called := false
+	scanner, err := NewFunc("a", func() []string {
+		called = true
+		return []string{"getopt", "x"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetArgs([]string{"getopt", "-a"})
+
+	if !scanner.Scan() || scanner.Opt() == nil || scanner.Opt().Opt != 'a' {
+		t.Errorf("expected option -a from argv set by SetArgs, got %v (err %v)", scanner.Opt(), scanner.Err())
+	}
+	if called {
+		t.Error("expected provider not to be called after SetArgs")
+	}
+	if args := scanner.Args(); len(args) != 0 {
+		t.Errorf("expected no remaining arguments, got %q", args)
+	}
+}
+
+func TestTerminatorAsRequiredArgument(t *testing.T) {
+	examples := []struct {
+		argv      []string
+		expected  []*Option
+		remaining []string
+	}{
+		{[]string{"getopt", "-o", "--"}, []*Option{{Opt: 'o', Arg: optArg("--")}}, nil},
+		{[]string{"getopt", "-o", "--", "x"}, []*Option{{Opt: 'o', Arg: optArg("--")}}, []string{"x"}},
+		{[]string{"getopt", "-vo", "--", "-v"}, []*Option{{Opt: 'v'}, {Opt: 'o', Arg: optArg("--")}, {Opt: 'v'}}, nil},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("o:v", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, errors, remaining := collectOptions(scanner)
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(ex.expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual(ex.remaining, remaining) {
+			t.Errorf("example %d: expected remaining\n%s\ngot\n%s", i+1, dumpRemaining(ex.remaining), dumpRemaining(remaining))
+		}
+	}
+}
+
+func TestScanAfterTerminator(t *testing.T) {
+	examples := []struct {
+		argv      []string
+		remaining []string
+	}{
+		{[]string{"getopt", "-a", "--", "-a", "arg1"}, []string{"-a", "arg1"}},
+		{[]string{"getopt", "-a", "--", "--", "arg1"}, []string{"--", "arg1"}},
+		{[]string{"getopt", "-a", "--"}, nil},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("a", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for scanner.Scan() {
+		}
+		for j := 0; j < 2; j++ {
+			if scanner.Scan() {
+				t.Errorf("example %d: expected Scan() to keep returning false", i+1)
+			}
+		}
+		if !reflect.DeepEqual(ex.remaining, scanner.Args()) {
+			t.Errorf("example %d: expected remaining %q, got %q", i+1, ex.remaining, scanner.Args())
+		}
+	}
+}
+
+func TestTry(t *testing.T) {
+	scanner, err := NewArgv("ab:c", []string{"getopt", "-a", "-bfoo", "-c", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scanner.Scan() {
+		t.Fatal("expected option")
+	}
+
+	errRollback := errors.New("rollback")
+	err = scanner.Try(func(s *Scanner) error {
+		for s.Scan() {
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Errorf("expected error %v, got %v", errRollback, err)
+	}
+	if expected, actual := []*Option{{Opt: 'a'}}, scanner.Options(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected options\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+	if expected, actual := []string{"-bfoo", "-c", "arg"}, scanner.Args(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected remaining %q, got %q", expected, actual)
+	}
+
+	err = scanner.Try(func(s *Scanner) error {
+		if !s.Scan() || s.Opt().Opt != 'b' {
+			return errors.New("expected -b")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []*Option{{Opt: 'a'}, {Opt: 'b', Arg: optArg("foo")}}
+	if actual := scanner.Options(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected options\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+	if opt := scanner.Opt(); opt == nil || opt.Opt != 'b' {
+		t.Errorf("expected current option -b, got %v", opt)
+	}
+	if expected, actual := []string{"-c", "arg"}, scanner.Args(); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected remaining %q, got %q", expected, actual)
+	}
+}
+
+func TestTryCollectInto(t *testing.T) {
+	scanner, err := NewArgv("I:", []string{"cc", "-Ia", "-Ib", "-Ic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var includes []string
+	scanner.CollectInto('I', &includes)
+	scanner.Scan()
+
+	errRollback := errors.New("rollback")
+	err = scanner.Try(func(s *Scanner) error {
+		s.Scan()
+		return errRollback
+	})
+	if err != errRollback {
+		t.Errorf("expected error %v, got %v", errRollback, err)
+	}
+	if expected := []string{"a"}; !reflect.DeepEqual(expected, includes) {
+		t.Errorf("expected %q after failed Try, got %q", expected, includes)
+	}
+
+	err = scanner.Try(func(s *Scanner) error {
+		s.Scan()
+		return s.Try(func(s *Scanner) error {
+			s.Scan()
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(expected, includes) {
+		t.Errorf("expected %q after successful Try, got %q", expected, includes)
+	}
+}
+
+func TestStopReason(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected StopReason
+	}{
+		{[]string{"getopt", "-a", "-b"}, StopEnd},
+		{[]string{"getopt"}, StopEnd},
+		{[]string{"getopt", "-a", "--", "-b"}, StopDoubleDash},
+		{[]string{"getopt", "-a", "arg", "-b"}, StopOperand},
+		{[]string{"getopt", "-a", "-", "-b"}, StopOperand},
+		{[]string{"getopt", "-a", "-z", "-b"}, StopError},
+		{[]string{"getopt", "-ac"}, StopError},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("abc:", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := scanner.StopReason(); actual != StopNone {
+			t.Errorf("example %d: expected StopNone before scanning, got %d", i+1, actual)
+		}
+		for scanner.Scan() {
+		}
+		if actual := scanner.StopReason(); actual != ex.expected {
+			t.Errorf("example %d: expected stop reason %d, got %d", i+1, ex.expected, actual)
+		}
+	}
+	scanner, err := NewArgv("ab", []string{"getopt", "-a", "arg", "-b", "arg2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for scanner.Scan() {
+	}
+	if actual := scanner.StopReason(); actual != StopOperand {
+		t.Fatalf("expected stop reason %d, got %d", StopOperand, actual)
+	}
+	scanner.NextArg()
+	if !scanner.Scan() {
+		t.Fatal("expected option after resuming")
+	}
+	if actual := scanner.StopReason(); actual != StopNone {
+		t.Errorf("expected stop reason %d after resuming, got %d", StopNone, actual)
+	}
+}
+
+func TestHasOption(t *testing.T) {
+	scanner, err := NewArgv("ab", []string{"getopt", "-ab", "--", "-a", "arg1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		hasOption bool
+		scan      bool
+	}{
+		{true, true},   // -a
+		{true, true},   // -b
+		{false, false}, // --
+		{false, false}, // -a following -- is an operand
+	}
+	for i, step := range steps {
+		for j := 0; j < 2; j++ {
+			if actual := scanner.HasOption(); actual != step.hasOption {
+				t.Errorf("step %d: expected HasOption() == %v", i+1, step.hasOption)
+			}
+		}
+		if actual := scanner.Scan(); actual != step.scan {
+			t.Errorf("step %d: expected Scan() == %v", i+1, step.scan)
+		}
+	}
+	if expected := []string{"-a", "arg1"}; !reflect.DeepEqual(expected, scanner.Args()) {
+		t.Errorf("expected remaining %q, got %q", expected, scanner.Args())
+	}
+
+	scanner, err = NewArgv("ab", []string{"getopt", "arg1", "-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanner.HasOption() {
+		t.Errorf("expected no option at operand")
+	}
+}
+
+func TestErr(t *testing.T) {
+	examples := []struct {
+		optstring string
+		argv      []string
+		err       error
+	}{
+		{
+			"ab",
+			[]string{"getopt", "-a", "-b", "arg1"},
+			nil,
+		},
+		{
+			"ab",
+			[]string{"getopt", "-a", "-z", "-b"},
+			InvalidOptionError('z'),
+		},
+		{
+			"ab:",
+			[]string{"getopt", "-a", "-b"},
+			MissingArgumentError('b'),
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for scanner.Scan() {
+			scanner.Option()
+		}
+		if scanner.Err() != ex.err {
+			t.Errorf("example %d: expected error %v, got %v", i+1, ex.err, scanner.Err())
+		}
+	}
+}
+
+func TestOptionStringOk(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected string
+		ok       bool
+	}{
+		{nil, "", false},
+		{new(string), "", true},
+		{optArg("foo"), "foo", true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'o', Arg: ex.arg}
+		actual, ok := opt.StringOk()
+		if actual != ex.expected || ok != ex.ok {
+			t.Errorf("example %d: expected (%q, %t), got (%q, %t)", i+1, ex.expected, ex.ok, actual, ok)
+		}
+	}
+}
+
+func TestOptionSecret(t *testing.T) {
+	opt := &Option{Opt: 'p', Arg: optArg("hunter2")}
+	secret := opt.Secret()
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v", "%q"} {
+		if actual := fmt.Sprintf(format, secret); strings.Contains(actual, "hunter2") {
+			t.Errorf("%s: secret leaked: %s", format, actual)
+		}
+	}
+	if expected, actual := "***", secret.String(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+	if expected, actual := "hunter2", secret.Reveal(); actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestOptionOneOf(t *testing.T) {
+	allowed := []string{"auto", "always", "never", "Info", "info-verbose"}
+	examples := []struct {
+		arg      *string
+		fold     bool
+		abbrev   bool
+		expected string
+		err      bool
+	}{
+		{optArg("never"), false, false, "never", false},
+		{optArg("NEVER"), false, false, "", true},
+		{optArg("NEVER"), true, false, "never", false},
+		{optArg("nev"), false, false, "", true},
+		{optArg("nev"), false, true, "never", false},
+		{optArg("NeV"), true, true, "never", false},
+		{optArg("al"), false, true, "always", false},
+		{optArg("a"), false, true, "", true},
+		{optArg("info"), true, true, "Info", false},
+		{optArg("inf"), true, true, "", true},
+		{optArg("inf"), false, true, "info-verbose", false},
+		{optArg(""), false, true, "", true},
+		{nil, false, false, "", true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'c', Arg: ex.arg}
+		actual, err := opt.OneOf(allowed, ex.fold, ex.abbrev)
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if actual != ex.expected {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+
+	opt := &Option{Opt: 'c', Arg: optArg("a")}
+	if _, err := opt.OneOf(allowed, false, true); err == nil || !strings.Contains(err.Error(), "auto, always") {
+		t.Errorf("expected ambiguous error listing candidates, got %v", err)
+	}
+}
+
+func TestOptionWithUnit(t *testing.T) {
+	units := map[string]float64{"us": 0.000001, "ms": 0.001, "s": 1, "m": 60}
+	examples := []struct {
+		arg      *string
+		expected float64
+		err      bool
+	}{
+		{optArg("250ms"), 0.25, false},
+		{optArg("2s"), 2, false},
+		{optArg("1.5m"), 90, false},
+		{optArg("500us"), 0.0005, false},
+		{optArg("3"), 3, false},
+		{optArg("-1s"), -1, false},
+		{optArg("10h"), 0, true},
+		{optArg("ms"), 0, true},
+		{optArg("x5s"), 0, true},
+		{nil, 0, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 't', Arg: ex.arg}
+		actual, err := opt.WithUnit(units)
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if actual != ex.expected {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+
+	// unknown suffix ending in a known unit
+	opt := &Option{Opt: 't', Arg: optArg("5ms")}
+	if _, err := opt.WithUnit(map[string]float64{"s": 1}); err == nil || err.Error() != `invalid number or unknown unit: "5ms"` {
+		t.Errorf("expected unknown unit error for the full argument, got %v", err)
+	}
+}
+
+func TestOptionLines(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected []string
+	}{
+		{optArg("one\ntwo\nthree"), []string{"one", "two", "three"}},
+		{optArg("one\ntwo\n"), []string{"one", "two"}},
+		{optArg("one\n\n"), []string{"one", ""}},
+		{optArg("single"), []string{"single"}},
+		{new(string), []string{}},
+		{nil, nil},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'm', Arg: ex.arg}
+		if actual := opt.Lines(); !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionStringMap(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected map[string]string
+		err      bool
+	}{
+		{optArg("k1=v1,k2=v2"), map[string]string{"k1": "v1", "k2": "v2"}, false},
+		{optArg("k1=,k2=a=b"), map[string]string{"k1": "", "k2": "a=b"}, false},
+		{optArg("k1=v1,k2"), nil, true},
+		{nil, map[string]string{}, false},
+		{new(string), map[string]string{}, false},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'D', Arg: ex.arg}
+		actual, err := opt.StringMap()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionEqual(t *testing.T) {
+	examples := []struct {
+		a, b     *Option
+		expected bool
+	}{
+		{&Option{Opt: 'a'}, &Option{Opt: 'a'}, true},
+		{&Option{Opt: 'a', Arg: optArg("x")}, &Option{Opt: 'a', Arg: optArg("x")}, true},
+		{&Option{Opt: 'a'}, &Option{Opt: 'b'}, false},
+		{&Option{Opt: 'a', Arg: optArg("x")}, &Option{Opt: 'a', Arg: optArg("y")}, false},
+		{&Option{Opt: 'a'}, &Option{Opt: 'a', Arg: optArg("x")}, false},
+		{&Option{Opt: 'a'}, &Option{Opt: 'a', Arg: new(string)}, false},
+		{&Option{Opt: 'a', Args: []string{"x", "y"}}, &Option{Opt: 'a', Args: []string{"x", "y"}}, true},
+		{&Option{Opt: 'a', Args: []string{"x", "y"}}, &Option{Opt: 'a', Args: []string{"x"}}, false},
+		{&Option{Opt: 'a'}, nil, false},
+		{nil, nil, true},
+	}
+
+	for i, ex := range examples {
+		if actual := ex.a.Equal(ex.b); actual != ex.expected {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+		if actual := ex.b.Equal(ex.a); actual != ex.expected {
+			t.Errorf("example %d: expected symmetric result %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionJSON(t *testing.T) {
+	examples := []struct {
+		opt  *Option
+		json string
+	}{
+		{&Option{Opt: 'v'}, `{"opt":"v"}`},
+		{&Option{Opt: 'o', Arg: optArg("value")}, `{"opt":"o","arg":"value"}`},
+		{&Option{Opt: 'o', Arg: new(string)}, `{"opt":"o","arg":""}`},
+		{&Option{Opt: 'x', Args: []string{"a", "b"}}, `{"opt":"x","args":["a","b"]}`},
+	}
+
+	for i, ex := range examples {
+		data, err := json.Marshal(ex.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != ex.json {
+			t.Errorf("example %d: expected %s, got %s", i+1, ex.json, data)
+		}
+		var actual Option
+		if err := json.Unmarshal(data, &actual); err != nil {
+			t.Fatal(err)
+		}
+		if !ex.opt.Equal(&actual) {
+			t.Errorf("example %d: round trip mismatch: expected %+v, got %+v", i+1, ex.opt, actual)
+		}
+	}
+
+	data, err := json.Marshal([]Option{{Opt: 'o', Arg: optArg("value")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"opt":"o","arg":"value"}]`; string(data) != expected {
+		t.Errorf("expected %s, got %s", expected, data)
+	}
+
+	var opt Option
+	if err := json.Unmarshal([]byte(`{"opt":"vv"}`), &opt); err == nil {
+		t.Errorf("expected error for invalid option name")
+	}
+}
+
+func TestOptionDefaults(t *testing.T) {
+	examples := []struct {
+		arg     *string
+		str     string
+		integer int
+		boolean bool
+	}{
+		{optArg("42"), "42", 42, true},
+		{optArg("false"), "false", 7, false},
+		{optArg("abc"), "abc", 7, true},
+		{nil, "def", 7, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'o', Arg: ex.arg}
+		if actual := opt.StringDefault("def"); actual != ex.str {
+			t.Errorf("example %d: expected string %q, got %q", i+1, ex.str, actual)
+		}
+		if actual := opt.IntDefault(7); actual != ex.integer {
+			t.Errorf("example %d: expected int %d, got %d", i+1, ex.integer, actual)
+		}
+		if actual := opt.BoolDefault(true); actual != ex.boolean {
+			t.Errorf("example %d: expected bool %v, got %v", i+1, ex.boolean, actual)
+		}
+	}
+}
+
+func TestOptionAppendTo(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected []string
+	}{
+		{[]string{"getopt", "-v"}, []string{"getopt", "-v"}},
+		{[]string{"getopt", "-ofile"}, []string{"getopt", "-ofile"}},
+		{[]string{"getopt", "-o", "file"}, []string{"getopt", "-ofile"}},
+		{[]string{"getopt", "-vo", "-x", "arg1"}, []string{"getopt", "-v", "-o-x", "arg1"}},
+		{[]string{"getopt", "-zfoo", "-z"}, []string{"getopt", "-zfoo", "-z"}},
+		{[]string{"getopt", "-z==5", "-o=x"}, []string{"getopt", "-z", "=5", "-o", "=x"}},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("o:vz::", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := []string{ex.argv[0]}
+		for scanner.Scan() {
+			opt, err := scanner.Option()
+			if err != nil {
+				t.Fatalf("example %d: unexpected error: %s", i+1, err)
+			}
+			actual = opt.AppendTo(actual)
+		}
+		actual = append(actual, scanner.Args()...)
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+
+	opt := &Option{Opt: 'o', Arg: new(string)}
+	if actual := opt.AppendTo(nil); !reflect.DeepEqual([]string{"-o", ""}, actual) {
+		t.Errorf("expected empty argument as separate element, got %q", actual)
+	}
+	opt = &Option{Opt: 'x', Args: []string{"a", "b"}}
+	if actual := opt.AppendTo(nil); !reflect.DeepEqual([]string{"-x", "a", "b"}, actual) {
+		t.Errorf("expected greedy arguments to follow the option, got %q", actual)
+	}
+}
+
+func TestAppendOption(t *testing.T) {
+	examples := []struct {
+		optstring string
+		argv      []string
+		expected  []string
+	}{
+		{
+			"o:vz::",
+			[]string{"getopt", "-vofile", "-z5", "-z", "-z=", "-z==5", "-o", "", "-o=x"},
+			[]string{"getopt", "-v", "-o", "file", "-z=5", "-z", "-z=", "-z==5", "-o", "", "-o", "=x"},
+		},
+		{
+			":a:b",
+			[]string{"getopt", "-a", "-b", "-a=5", "-ab"},
+			[]string{"getopt", "-a", "-b", "-a=5", "-ab"},
+		},
+		{
+			"-a",
+			[]string{"getopt", "x", "-a", "y"},
+			[]string{"getopt", "x", "-a", "y"},
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv(ex.optstring, ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner.SetAllowEmptyArg(true)
+		actual := []string{ex.argv[0]}
+		for scanner.Scan() {
+			opt, err := scanner.Option()
+			if err != nil {
+				t.Fatalf("example %d: unexpected error: %s", i+1, err)
+			}
+			actual = scanner.AppendOption(actual, opt)
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+
+		// round trip
+		expected := scanner.Options()
+		scanner.SetArgs(actual)
+		for scanner.Scan() {
+		}
+		if scanner.Err() != nil {
+			t.Fatalf("example %d: unexpected error: %s", i+1, scanner.Err())
+		}
+		if !reflect.DeepEqual(expected, scanner.Options()) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(expected), dumpOptions(scanner.Options()))
+		}
+	}
+
+	scanner, err := NewArgv("vx", []string{"find", "-xa", "b", ";", "-v", "-x", ";", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetGreedy('x', ";")
+	greedy := []string{"find"}
+	for scanner.Scan() {
+		greedy = scanner.AppendOption(greedy, scanner.Opt())
+	}
+	greedy = append(greedy, scanner.Args()...)
+	if expected := []string{"find", "-x", "a", "b", ";", "-v", "-x", ";", "arg"}; !reflect.DeepEqual(expected, greedy) {
+		t.Errorf("expected %q, got %q", expected, greedy)
+	}
+
+	scanner, err = NewArgv("", []string{"getopt", "-q=1", "-D="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner.SetPermissive(true)
+	var actual []string
+	for scanner.Scan() {
+		actual = scanner.AppendOption(actual, scanner.Opt())
+	}
+	if expected := []string{"-q=1", "-D"}; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestOptionCreateAppend(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.txt")
+	opt := &Option{Opt: 'o', Arg: &name}
+
+	for _, open := range []func() (io.WriteCloser, error){opt.Create, opt.Append, opt.Append} {
+		f, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(f, "line\n")
+		f.Close()
+	}
+	if data, err := os.ReadFile(name); err != nil || string(data) != "line\nline\nline\n" {
+		t.Errorf("unexpected file contents %q (err=%v)", data, err)
+	}
+
+	f, err := opt.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if data, err := os.ReadFile(name); err != nil || len(data) != 0 {
+		t.Errorf("expected Create to truncate the file, got %q (err=%v)", data, err)
+	}
+
+	if _, ok := f.(*os.File); !ok {
+		t.Errorf("expected *os.File, got %T", f)
+	}
+
+	opt = &Option{Opt: 'o', Arg: optArg("-")}
+	for _, open := range []func() (io.WriteCloser, error){opt.Create, opt.Append} {
+		w, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("unexpected error closing standard output: %v", err)
+		}
+		if _, err := os.Stdout.Stat(); err != nil {
+			t.Errorf("expected standard output to remain open, got %v", err)
+		}
+	}
+
+	opt = &Option{Opt: 'o'}
+	if _, err := opt.Create(); err != MissingArgumentError('o') {
+		t.Errorf("expected %v, got %v", MissingArgumentError('o'), err)
+	}
+}
+
+func TestOptionGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	examples := []struct {
+		arg      *string
+		expected []string
+		err      bool
+	}{
+		{optArg(filepath.Join(dir, "*.go")), []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")}, false},
+		{optArg(filepath.Join(dir, "*.md")), nil, false},
+		{optArg(filepath.Join(dir, "[")), nil, true},
+		{nil, nil, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'f', Arg: ex.arg}
+		actual, err := opt.Glob()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionColor(t *testing.T) {
+	examples := []struct {
+		arg     *string
+		r, g, b uint8
+		err     bool
+	}{
+		{optArg("#ff0000"), 0xff, 0, 0, false},
+		{optArg("#0A141e"), 0x0a, 0x14, 0x1e, false},
+		{optArg("#f00"), 0xff, 0, 0, false},
+		{optArg("red"), 0xff, 0, 0, false},
+		{optArg("Blue"), 0, 0, 0xff, false},
+		{optArg("#gg0000"), 0, 0, 0, true},
+		{optArg("#ff00"), 0, 0, 0, true},
+		{optArg("purplish"), 0, 0, 0, true},
+		{nil, 0, 0, 0, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'c', Arg: ex.arg}
+		r, g, b, err := opt.Color()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if r != ex.r || g != ex.g || b != ex.b {
+			t.Errorf("example %d: expected (%d, %d, %d), got (%d, %d, %d)", i+1, ex.r, ex.g, ex.b, r, g, b)
+		}
+	}
+}
+
+type testLevel int
+
+func (l *testLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("invalid level: %q", text)
+	}
+	return nil
+}
+
+func TestOptionUnmarshal(t *testing.T) {
+	var level testLevel
+	opt := &Option{Opt: 'l', Arg: optArg("high")}
+	if err := opt.Unmarshal(&level); err != nil || level != 2 {
+		t.Errorf("expected level 2, got %d (err=%v)", level, err)
+	}
+
+	opt = &Option{Opt: 'l', Arg: optArg("medium")}
+	if err := opt.Unmarshal(&level); err == nil {
+		t.Errorf("expected error for invalid level")
+	}
+
+	opt = &Option{Opt: 'l'}
+	if err := opt.Unmarshal(&level); err != MissingArgumentError('l') {
+		t.Errorf("expected %v, got %v", MissingArgumentError('l'), err)
+	}
+}
+
+func TestOptionUUID(t *testing.T) {
+	expected := [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	examples := []struct {
+		arg      *string
+		expected [16]byte
+		err      bool
+	}{
+		{optArg("123e4567-e89b-12d3-a456-426614174000"), expected, false},
+		{optArg("123E4567-E89B-12D3-A456-426614174000"), expected, false},
+		{optArg("{123e4567-e89b-12d3-a456-426614174000}"), expected, false},
+		{optArg("{123e4567-e89b-12d3-a456-426614174000"), [16]byte{}, true},
+		{optArg("123e4567e89b12d3a456426614174000"), [16]byte{}, true},
+		{optArg("123e4567-e89b-12d3-a456-42661417400g"), [16]byte{}, true},
+		{optArg("not-a-uuid"), [16]byte{}, true},
+		{nil, [16]byte{}, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'u', Arg: ex.arg}
+		actual, err := opt.UUID()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if actual != ex.expected {
+			t.Errorf("example %d: expected %x, got %x", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionAddr(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected netip.Addr
+		err      bool
+	}{
+		{optArg("192.168.1.1"), netip.AddrFrom4([4]byte{192, 168, 1, 1}), false},
+		{optArg("::1"), netip.IPv6Loopback(), false},
+		{optArg("192.168.1.1:80"), netip.Addr{}, true},
+		{optArg("host"), netip.Addr{}, true},
+		{nil, netip.Addr{}, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'a', Arg: ex.arg}
+		actual, err := opt.Addr()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if actual != ex.expected {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionMAC(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected net.HardwareAddr
+		err      bool
+	}{
+		{optArg("00:1a:2b:3c:4d:5e"), net.HardwareAddr{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}, false},
+		{optArg("00-1A-2B-3C-4D-5E"), net.HardwareAddr{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}, false},
+		{optArg("00:1a:2b:3c:4d"), nil, true},
+		{optArg("hostname"), nil, true},
+		{nil, nil, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'm', Arg: ex.arg}
+		actual, err := opt.MAC()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if !bytes.Equal(actual, ex.expected) {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionAddrPort(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected netip.AddrPort
+		err      bool
+	}{
+		{optArg("192.168.1.1:8080"), netip.AddrPortFrom(netip.AddrFrom4([4]byte{192, 168, 1, 1}), 8080), false},
+		{optArg("[::1]:53"), netip.AddrPortFrom(netip.IPv6Loopback(), 53), false},
+		{optArg("192.168.1.1"), netip.AddrPort{}, true},
+		{optArg("host:80"), netip.AddrPort{}, true},
+		{nil, netip.AddrPort{}, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'a', Arg: ex.arg}
+		actual, err := opt.AddrPort()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if actual != ex.expected {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionDurationSlice(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected []time.Duration
+		err      bool
+	}{
+		{optArg("1s,2s,5s"), []time.Duration{time.Second, 2 * time.Second, 5 * time.Second}, false},
+		{optArg("100ms"), []time.Duration{100 * time.Millisecond}, false},
+		{optArg("1s,x,5s"), nil, true},
+		{nil, nil, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 't', Arg: ex.arg}
+		actual, err := opt.DurationSlice()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+
+	opt := &Option{Opt: 't', Arg: optArg("1s,x")}
+	if _, err := opt.DurationSlice(); err == nil || !strings.Contains(err.Error(), `element 1 ("x")`) {
+		t.Errorf("expected error to identify failed element, got %v", err)
+	}
+}
+
+func TestOptionPercent(t *testing.T) {
+	examples := []struct {
+		arg      *string
+		expected float64
+		err      bool
+	}{
+		{optArg("50%"), 0.5, false},
+		{optArg("0.5"), 0.5, false},
+		{optArg("100%"), 1, false},
+		{optArg("abc"), 0, true},
+		{optArg("%"), 0, true},
+		{nil, 0, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'r', Arg: ex.arg}
+		actual, err := opt.Percent()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if actual != ex.expected {
+			t.Errorf("example %d: expected %v, got %v", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestOptionIntRangePair(t *testing.T) {
+	examples := []struct {
+		arg    *string
+		lo, hi int
+		err    bool
+	}{
+		{optArg("10-20"), 10, 20, false},
+		{optArg("-5--1"), -5, -1, false},
+		{optArg("-5-3"), -5, 3, false},
+		{optArg("7-7"), 7, 7, false},
+		{optArg("10"), 0, 0, true},
+		{optArg("-10"), 0, 0, true},
+		{optArg("20-10"), 0, 0, true},
+		{optArg("a-b"), 0, 0, true},
+		{optArg("1-b"), 0, 0, true},
+		{nil, 0, 0, true},
+	}
+
+	for i, ex := range examples {
+		opt := &Option{Opt: 'l', Arg: ex.arg}
+		lo, hi, err := opt.IntRangePair()
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if lo != ex.lo || hi != ex.hi {
+			t.Errorf("example %d: expected %d-%d, got %d-%d", i+1, ex.lo, ex.hi, lo, hi)
+		}
+	}
+}
+
+func TestOptionScanf(t *testing.T) {
+	var w, h int
+
+	opt := &Option{Opt: 'p', Arg: optArg("10x20")}
+	if n, err := opt.Scanf("%dx%d", &w, &h); err != nil || n != 2 || w != 10 || h != 20 {
+		t.Errorf("expected 10x20, got %dx%d (n=%d, err=%v)", w, h, n, err)
+	}
+
+	opt = &Option{Opt: 'p', Arg: optArg("10by20")}
+	if _, err := opt.Scanf("%dx%d", &w, &h); err == nil {
+		t.Errorf("expected format mismatch error")
+	}
+
+	opt = &Option{Opt: 'p'}
+	if _, err := opt.Scanf("%dx%d", &w, &h); err != MissingArgumentError('p') {
+		t.Errorf("expected %v, got %v", MissingArgumentError('p'), err)
+	}
+}
+
+func parseOptions(t *testing.T, optstring string, argv []string) ([]*Option, []error, []string) {
+	scanner, err := NewArgv(optstring, argv)
+	if err != nil {
+		t.Fatal(err)
+		return nil, nil, nil
+	}
+	return collectOptions(scanner)
+}
+
+func collectOptions(scanner *Scanner) ([]*Option, []error, []string) {
+	var options []*Option
+	var errors []error
+
+	for scanner.Scan() {
+		opt, _ := scanner.Option()
+		options = append(options, opt)
+	}
+	if _, err := scanner.Option(); err != nil {
+		errors = append(errors, err)
+	}
+
+	return options, errors, scanner.Args()
+}
+
+func scanOptions(t *testing.T, optstring string, argv []string) ([]*Option, []error, []string) {
+	var options []*Option
+	var errors []error
+
+	scanner, err := NewArgv(optstring, argv)
+	if err != nil {
+		t.Fatal(err)
+		return nil, nil, nil
+	}
+	for scanner.Scan() {
+		options = append(options, scanner.Opt())
+	}
+	if err := scanner.Err(); err != nil {
+		errors = append(errors, err)
 	}
 
 	return options, errors, scanner.Args()
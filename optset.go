@@ -0,0 +1,161 @@
+package getopt
+
+import (
+	"fmt"
+	"path"
+	"unicode/utf8"
+)
+
+// Mode contains option string flags set by its prefix.
+type Mode int
+
+const (
+	// All option arguments are optional (':' prefix)
+	ModeOptionalArgs Mode = 1 << iota
+	// Stop scanning at the first operand even in interspersed mode ('+' prefix)
+	ModePosix
+	// Return operands in place as options with Opt == 0 ('-' prefix)
+	ModeInOrder
+)
+
+// ParsedOpt contains an option declared in option string.
+type ParsedOpt struct {
+	// Option character
+	Char rune
+	// Argument kind: ArgNone, ArgRequired or ArgOptional
+	Arg int
+}
+
+// ParseOptString parses optstring, see NewArgv for its syntax, and returns declared
+// options in declaration order and mode flags set by the optstring prefix.
+func ParseOptString(optstring string) (opts []ParsedOpt, mode Mode, err error) {
+	if !utf8.ValidString(optstring) {
+		return nil, 0, fmt.Errorf("optstring is not valid UTF-8: %q", optstring)
+	}
+	i := 0
+prefix:
+	for ; i < len(optstring); i++ {
+		switch optstring[i] {
+		case ':':
+			mode |= ModeOptionalArgs
+		case '+':
+			mode |= ModePosix
+		case '-':
+			mode |= ModeInOrder
+		default:
+			break prefix
+		}
+	}
+
+	seen := make(map[byte]bool)
+	for ; i < len(optstring); i++ {
+		c := optstring[i]
+		if c == ':' {
+			continue
+		}
+		if !isOptionChar(c) {
+			return nil, 0, fmt.Errorf("invalid optstring character: %q", c)
+		}
+		kind := ArgNone
+		if i+1 < len(optstring) && optstring[i+1] == ':' {
+			kind = ArgRequired
+			if i+2 < len(optstring) && optstring[i+2] == ':' {
+				kind = ArgOptional
+			}
+		}
+		if !seen[c] {
+			seen[c] = true
+			opts = append(opts, ParsedOpt{Char: rune(c), Arg: kind})
+		}
+	}
+	return opts, mode, nil
+}
+
+// OptSet contains a compiled option string. It can be used to create scanners for
+// many command lines without parsing the option string each time.
+type OptSet struct {
+	// Declared options
+	opts []ParsedOpt
+	// Argument kind of each accepted option character
+	kinds map[byte]int
+	// Option string mode flags
+	mode Mode
+}
+
+// Compile parses optstring, see NewArgv for its syntax.
+func Compile(optstring string) (*OptSet, error) {
+	opts, mode, err := ParseOptString(optstring)
+	if err != nil {
+		return nil, err
+	}
+	set := &OptSet{
+		opts:  opts,
+		kinds: make(map[byte]int, len(opts)),
+		mode:  mode,
+	}
+	for _, opt := range opts {
+		set.kinds[byte(opt.Char)] = opt.Arg
+	}
+	return set, nil
+}
+
+// Scanner returns a new options scanner using passed argv as the command line argument source.
+// The first element of argv is treated as the program name. If argv is empty, the
+// scanner reports an error through Err.
+func (o *OptSet) Scanner(argv []string) *Scanner {
+	s := &Scanner{
+		argv:       argv,
+		set:        o,
+		optind:     1,
+		optpos:     1,
+		optindex:   -1,
+		terminator: "--",
+		bundling:   true,
+
+		honorPosixlyCorrect: true,
+	}
+	if len(argv) > 0 {
+		s.progname = path.Base(argv[0])
+	} else {
+		s.err = errEmptyArgv
+	}
+	return s
+}
+
+// ParseBatch compiles optstring once and parses each of argvs, returning options
+// parsed from every argv and a parallel slice of errors, nil for argvs parsed
+// successfully. Options parsed before an error are still returned.
+func ParseBatch(optstring string, argvs [][]string) ([][]*Option, []error) {
+	options := make([][]*Option, len(argvs))
+	errs := make([]error, len(argvs))
+
+	set, err := Compile(optstring)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return options, errs
+	}
+
+	for i, argv := range argvs {
+		if len(argv) == 0 {
+			errs[i] = errEmptyArgv
+			continue
+		}
+		s := set.Scanner(argv)
+		for s.Scan() {
+		}
+		options[i] = s.Options()
+		errs[i] = s.Err()
+	}
+	return options, errs
+}
+
+// argKind looks up argument kind of the option c.
+func (o *OptSet) argKind(c byte) (int, bool) {
+	kind, ok := o.kinds[c]
+	if kind == ArgRequired && o.mode&ModeOptionalArgs != 0 {
+		kind = ArgOptional
+	}
+	return kind, ok
+}
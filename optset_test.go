@@ -0,0 +1,217 @@
+package getopt
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	set, err := Compile("a:bz::v")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, argv := range [][]string{
+		{"getopt", "-ba42", "arg1"},
+		{"getopt", "-b", "-a", "42", "arg1"},
+	} {
+		actual, errors, remaining := collectOptions(set.Scanner(argv))
+		expected := []*Option{{Opt: 'b'}, {Opt: 'a', Arg: optArg("42")}}
+		if len(errors) > 0 {
+			t.Fatalf("example %d: unexpected errors\n%s", i+1, dumpErrors(errors))
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(expected), dumpOptions(actual))
+		}
+		if !reflect.DeepEqual([]string{"arg1"}, remaining) {
+			t.Errorf("example %d: unexpected remaining arguments %q", i+1, remaining)
+		}
+	}
+
+	if _, err := Compile("a-b"); err == nil {
+		t.Errorf("expected error for invalid optstring")
+	}
+}
+
+func TestOptSetScannerEmptyArgv(t *testing.T) {
+	set, err := Compile("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, argv := range [][]string{nil, {}} {
+		scanner := set.Scanner(argv)
+		if scanner.HasOption() {
+			t.Errorf("example %d: expected no option", i+1)
+		}
+		if scanner.Scan() {
+			t.Errorf("example %d: expected Scan to return false", i+1)
+		}
+		if scanner.Err() != errEmptyArgv {
+			t.Errorf("example %d: expected error %v, got %v", i+1, errEmptyArgv, scanner.Err())
+		}
+	}
+}
+
+func TestParseOptString(t *testing.T) {
+	examples := []struct {
+		optstring string
+		opts      []ParsedOpt
+		mode      Mode
+		err       bool
+	}{
+		{
+			":a:b::c",
+			[]ParsedOpt{{'a', ArgRequired}, {'b', ArgOptional}, {'c', ArgNone}},
+			ModeOptionalArgs,
+			false,
+		},
+		{
+			"+ab:a",
+			[]ParsedOpt{{'a', ArgNone}, {'b', ArgRequired}},
+			ModePosix,
+			false,
+		},
+		{
+			"-:a",
+			[]ParsedOpt{{'a', ArgNone}},
+			ModeInOrder | ModeOptionalArgs,
+			false,
+		},
+		{
+			":+ab",
+			[]ParsedOpt{{'a', ArgNone}, {'b', ArgNone}},
+			ModePosix | ModeOptionalArgs,
+			false,
+		},
+		{
+			"+:ab",
+			[]ParsedOpt{{'a', ArgNone}, {'b', ArgNone}},
+			ModePosix | ModeOptionalArgs,
+			false,
+		},
+		{
+			":-a",
+			[]ParsedOpt{{'a', ArgNone}},
+			ModeInOrder | ModeOptionalArgs,
+			false,
+		},
+		{
+			":",
+			nil,
+			ModeOptionalArgs,
+			false,
+		},
+		{
+			"",
+			nil,
+			0,
+			false,
+		},
+		{
+			"a+b",
+			nil,
+			0,
+			true,
+		},
+		{
+			"a-b",
+			nil,
+			0,
+			true,
+		},
+		{
+			"a?",
+			nil,
+			0,
+			true,
+		},
+		{
+			"a\xff",
+			nil,
+			0,
+			true,
+		},
+	}
+
+	for i, ex := range examples {
+		opts, mode, err := ParseOptString(ex.optstring)
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(ex.opts, opts) || ex.mode != mode {
+			t.Errorf("example %d: expected %v (mode %d), got %v (mode %d)", i+1, ex.opts, ex.mode, opts, mode)
+		}
+	}
+}
+
+var benchArgv = []string{"getopt", "-ba42", "-v", "-z", "--", "-w", "arg1", "arg2"}
+
+func TestParseBatch(t *testing.T) {
+	argvs := [][]string{
+		{"getopt", "-ba42", "arg1"},
+		{"getopt", "-v"},
+		{"getopt", "-b", "-x", "-v"},
+		{},
+	}
+	options, errs := ParseBatch("a:bv", argvs)
+
+	expected := [][]*Option{
+		{{Opt: 'b'}, {Opt: 'a', Arg: optArg("42")}},
+		{{Opt: 'v'}},
+		{{Opt: 'b'}},
+		nil,
+	}
+	expectedErrs := []error{nil, nil, InvalidOptionError('x'), errEmptyArgv}
+	for i := range argvs {
+		if !reflect.DeepEqual(expected[i], options[i]) {
+			t.Errorf("example %d: expected options\n%s\ngot\n%s", i+1, dumpOptions(expected[i]), dumpOptions(options[i]))
+		}
+		if errs[i] != expectedErrs[i] {
+			t.Errorf("example %d: expected error %v, got %v", i+1, expectedErrs[i], errs[i])
+		}
+	}
+
+	_, errs = ParseBatch("a-b", argvs)
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("example %d: expected error for invalid optstring", i+1)
+		}
+	}
+}
+
+func BenchmarkNewArgv(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		scanner, err := NewArgv("a:bz::vcdefghijk:l:m:n:", benchArgv)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for scanner.Scan() {
+		}
+	}
+}
+
+func BenchmarkOptSetScanner(b *testing.B) {
+	set, err := Compile("a:bz::vcdefghijk:l:m:n:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scanner := set.Scanner(benchArgv)
+		for scanner.Scan() {
+		}
+	}
+}
+
+func BenchmarkParseBatch(b *testing.B) {
+	argvs := make([][]string, 1000)
+	for i := range argvs {
+		argvs[i] = []string{"getopt", "-b", "-a", strconv.Itoa(i), "-v", "arg"}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParseBatch("a:bv", argvs)
+	}
+}
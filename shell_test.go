@@ -0,0 +1,94 @@
+package getopt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArgsString(t *testing.T) {
+	examples := []struct {
+		argv     []string
+		expected string
+	}{
+		{
+			[]string{"getopt", "-a"},
+			"",
+		},
+		{
+			[]string{"getopt", "-a", "arg1", "path/to/file.txt"},
+			"arg1 path/to/file.txt",
+		},
+		{
+			[]string{"getopt", "-a", "with space", "it's", ""},
+			`'with space' 'it'\''s' ''`,
+		},
+		{
+			[]string{"getopt", "--", "-b", "$HOME", "a\"b"},
+			`-b '$HOME' 'a"b'`,
+		},
+	}
+
+	for i, ex := range examples {
+		scanner, err := NewArgv("a", ex.argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for scanner.Scan() {
+		}
+		if actual := scanner.ArgsString(); actual != ex.expected {
+			t.Errorf("example %d: expected %s, got %s", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestWordSplit(t *testing.T) {
+	examples := []struct {
+		s        string
+		expected []string
+		err      bool
+	}{
+		{"", nil, false},
+		{"  getopt  -a\targ1 ", []string{"getopt", "-a", "arg1"}, false},
+		{`getopt -o 'with space' "it's" ''`, []string{"getopt", "-o", "with space", "it's", ""}, false},
+		{`a"b c"'d e'f`, []string{"ab cd ef"}, false},
+		{`"\"\$\a" with\ space`, []string{`"$\a`, "with space"}, false},
+		{`'unterminated`, nil, true},
+		{`"unterminated`, nil, true},
+	}
+
+	for i, ex := range examples {
+		actual, err := WordSplit(ex.s)
+		if (err != nil) != ex.err {
+			t.Errorf("example %d: unexpected error value: %v", i+1, err)
+			continue
+		}
+		if !reflect.DeepEqual(ex.expected, actual) {
+			t.Errorf("example %d: expected %q, got %q", i+1, ex.expected, actual)
+		}
+	}
+}
+
+func TestNewString(t *testing.T) {
+	cmdline := `prog -b -a "hello world" -- 'arg 1' arg2`
+	argv := []string{"prog", "-b", "-a", "hello world", "--", "arg 1", "arg2"}
+
+	scanner, err := NewString("a:b", cmdline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, errors, remaining := collectOptions(scanner)
+	expected, expectedErrors, expectedRemaining := parseOptions(t, "a:b", argv)
+	if !reflect.DeepEqual(expected, actual) || !reflect.DeepEqual(expectedErrors, errors) {
+		t.Errorf("expected options\n%s\ngot\n%s", dumpOptions(expected), dumpOptions(actual))
+	}
+	if !reflect.DeepEqual(expectedRemaining, remaining) {
+		t.Errorf("expected remaining %q, got %q", expectedRemaining, remaining)
+	}
+	if scanner.ProgramName() != "prog" {
+		t.Errorf("expected program name prog, got %s", scanner.ProgramName())
+	}
+
+	if _, err := NewString("a", `prog -a "unterminated`); err == nil {
+		t.Errorf("expected error for unterminated quote")
+	}
+}